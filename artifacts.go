@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeArtifactList writes the final artifacts of every successful
+// build to path, one absolute path per line, followed by the artifacts of
+// the run as a whole, such as checksum files. A path of "-" writes the
+// list to stdout.
+func writeArtifactList(path string, results []BuildResult, runArtifacts []string) error {
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+
+		for _, a := range r.Artifacts {
+			if _, err := bw.WriteString(a + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, a := range runArtifacts {
+		if _, err := bw.WriteString(a + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// collectArtifacts copies (or moves, if move is true) the final artifacts
+// of every successful build into dir, and updates the results to point to
+// their new location. Artifacts keep their file names, so two artifacts
+// with the same name are an error.
+func collectArtifacts(dir string, move bool, results []BuildResult) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	seen := make(map[string]string)
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+
+		for _, a := range r.Artifacts {
+			name := filepath.Base(a)
+			if other, ok := seen[name]; ok {
+				return fmt.Errorf(
+					"artifacts %s and %s have the same name", other, a)
+			}
+			seen[name] = a
+		}
+	}
+
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+
+		for j, src := range results[i].Artifacts {
+			dst := filepath.Join(dir, filepath.Base(src))
+			if dst == src {
+				continue
+			}
+
+			if move {
+				err = moveFile(src, dst)
+			} else {
+				err = copyFile(src, dst)
+			}
+			if err != nil {
+				return err
+			}
+
+			results[i].Artifacts[j] = dst
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the file src to dst, keeping its permissions.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// Neither the umask nor an existing dst may change the permissions
+	return os.Chmod(dst, info.Mode().Perm())
+}
+
+// moveFile moves the file src to dst, falling back to a copy when the two
+// are on different file systems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteArtifactList(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	results := []BuildResult{
+		{Artifacts: []string{"/a.tar.gz", "/a.sha256"}},
+		{Artifacts: []string{"/b"}, Err: errors.New("failed")},
+		{Artifacts: []string{"/c"}},
+	}
+
+	path := filepath.Join(td, "list")
+	if err := writeArtifactList(path, results, []string{"/checksums.txt"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "/a.tar.gz\n/a.sha256\n/c\n/checksums.txt\n" {
+		t.Fatalf("bad: %q", data)
+	}
+}
+
+func TestCollectArtifacts(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var results []BuildResult
+	for _, name := range []string{"linux/app", "windows/app.exe"} {
+		path := filepath.Join(td, "build", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		results = append(results, BuildResult{Artifacts: []string{path}})
+	}
+
+	// Copy leaves the originals in place
+	dir := filepath.Join(td, "artifacts")
+	if err := collectArtifacts(dir, false, results); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{filepath.Join(dir, "app"), filepath.Join(dir, "app.exe")}
+	for i, r := range results {
+		if !reflect.DeepEqual(r.Artifacts, []string{expected[i]}) {
+			t.Fatalf("bad: %#v", r.Artifacts)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(td, "build", "linux", "app")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Artifacts with the same name can't share the directory
+	results = append(results, BuildResult{
+		Artifacts: []string{filepath.Join(td, "build", "linux", "app")},
+	})
+	err = collectArtifacts(filepath.Join(td, "other"), true, results)
+	if err == nil || !strings.Contains(err.Error(), "same name") {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// attestSubject is a subject of an in-toto statement: an artifact and its
+// digest. Its JSON form follows the in-toto subject schema, so it must not
+// change.
+type attestSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// attestSubjects returns a subject for every artifact of the successful
+// builds of s, and for the artifacts of the run. The name of a subject is
+// the path of the artifact relative to the current directory, with
+// forward slashes. Digests already in digests aren't computed again.
+func attestSubjects(s *Summary, digests artifactDigests) ([]attestSubject, error) {
+	var paths []string
+	for _, b := range s.Builds {
+		if b.Error == "" {
+			paths = append(paths, b.Artifacts...)
+		}
+	}
+	paths = append(paths, s.Artifacts...)
+
+	subjects := make([]attestSubject, 0, len(paths))
+	for _, path := range paths {
+		sum, err := digests.sha256(path)
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := attestName(path)
+		if err != nil {
+			return nil, err
+		}
+
+		subjects = append(subjects, attestSubject{
+			Name:   name,
+			Digest: map[string]string{"sha256": sum},
+		})
+	}
+
+	return subjects, nil
+}
+
+// attestName returns the subject name of the artifact at path: its path
+// relative to the current directory, so that it doesn't depend on where
+// the build ran.
+func attestName(path string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		// Such as on another drive on Windows
+		return filepath.ToSlash(abs), nil
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// writeAttestSubjects writes the subjects of the artifacts of s to path as
+// a JSON array, for an attestation signer to use.
+func writeAttestSubjects(path string, s *Summary, digests artifactDigests) error {
+	subjects, err := attestSubjects(s, digests)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(subjects, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
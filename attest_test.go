@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteAttestSubjects(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	app := filepath.Join(td, "app")
+	if err := ioutil.WriteFile(app, []byte("app"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sums := filepath.Join(td, "checksums.txt")
+	if err := ioutil.WriteFile(sums, []byte("sums"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	s := &Summary{
+		Builds: []SummaryBuild{
+			{Platform: "linux/amd64", Artifacts: []string{app}},
+			{Platform: "windows/amd64", Error: "failed"},
+		},
+		Artifacts: []string{sums},
+	}
+
+	// Known digests aren't computed again
+	digests := artifactDigests{sums: "known"}
+
+	path := filepath.Join(td, "subjects.json")
+	if err := writeAttestSubjects(path, s, digests); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var actual []map[string]interface{}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Names are relative to the current directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	name := func(path string) string {
+		rel, err := filepath.Rel(wd, path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	expected := []map[string]interface{}{
+		{
+			"name": name(app),
+			"digest": map[string]interface{}{
+				"sha256": "a172cedcae47474b615c54d510a5d84a8dea3032e958587430b413538be3f333",
+			},
+		},
+		{
+			"name":   name(sums),
+			"digest": map[string]interface{}{"sha256": "known"},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestAttestSubjects_empty(t *testing.T) {
+	subjects, err := attestSubjects(&Summary{}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := json.Marshal(subjects)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "[]" {
+		t.Fatalf("bad: %s", data)
+	}
+}
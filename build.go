@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// errBuildCancelled is the error of builds stopped by Builder.Cancel.
+var errBuildCancelled = errors.New("cancelled")
+
+// Builder runs a set of planned builds in parallel.
+type Builder struct {
+	// Parallel is the maximum number of builds to run at once.
+	Parallel int
+
+	// WorkerGoCache, if set, gives each worker its own GOCACHE in a
+	// "worker-N" directory below it. It must be an absolute path.
+	WorkerGoCache string
+
+	// Cancel, if set, stops the builds once it is closed: running builds
+	// are killed and builds that haven't started don't start. Both are
+	// StatusCancelled.
+	Cancel <-chan struct{}
+
+	// Timeout, if set, is how long a single build may take. A build that
+	// takes longer is killed and is StatusTimedOut.
+	Timeout time.Duration
+
+	// PostBuild, if set, is called after every successful build to
+	// post-process it, for example by packaging the binary. It may update
+	// the artifacts of the result. Returning an error fails the build.
+	PostBuild func(opts *CompileOpts, result *BuildResult) error
+
+	// OnStart, if set, is called when a build starts, once it has a
+	// worker.
+	OnStart func(opts *CompileOpts)
+
+	// OnResult, if set, is called for every finished build, whether it
+	// succeeded or failed, after PostBuild, and for every build cancelled
+	// before it started. It only observes the result,
+	// for example for logging or metrics.
+	//
+	// The callbacks run in the worker goroutine of the build, so they
+	// run concurrently with each other for different builds and count
+	// towards Parallel.
+	OnResult func(result BuildResult)
+
+	// Stats records the utilization of the workers during Build. It is
+	// created by the first call to Build.
+	Stats *poolStats
+
+	// compile builds a single package, GoCrossCompile if nil.
+	compile func(opts *CompileOpts) error
+}
+
+// Build runs every build and returns their results, in the order they
+// finished.
+func (b *Builder) Build(jobs []*CompileOpts) []BuildResult {
+	compile := b.compile
+	if compile == nil {
+		compile = GoCrossCompile
+	}
+
+	var resultsLock sync.Mutex
+	var wg sync.WaitGroup
+	results := make([]BuildResult, 0, len(jobs))
+	if b.Stats == nil {
+		b.Stats = newPoolStats(b.Parallel)
+	}
+
+	// The pool of workers is a channel of worker IDs. A build takes an ID
+	// for as long as it runs, which bounds the parallelism and gives each
+	// build a stable identity for per-worker resources.
+	workers := make(chan int, b.Parallel)
+	for i := 0; i < b.Parallel; i++ {
+		workers <- i
+	}
+
+	// Closing Cancel kills the running builds through their context
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		select {
+		case <-b.Cancel:
+			stop()
+		case <-ctx.Done():
+		}
+	}()
+
+	finish := func(result BuildResult) {
+		if b.OnResult != nil {
+			b.OnResult(result)
+		}
+
+		resultsLock.Lock()
+		defer resultsLock.Unlock()
+		results = append(results, result)
+	}
+	for _, opts := range jobs {
+		// Start the goroutine that will do the actual build
+		wg.Add(1)
+		go func(opts *CompileOpts) {
+			defer wg.Done()
+			worker := <-workers
+			defer func() { workers <- worker }()
+
+			result := BuildResult{
+				Platform:  opts.Platform,
+				Package:   opts.PackagePath,
+				GoVersion: opts.GoVersion,
+				Cover:     opts.Cover,
+			}
+			if b.cancelled() {
+				result.Status, result.Err = StatusCancelled, errBuildCancelled
+				finish(result)
+				return
+			}
+
+			b.Stats.Start()
+			defer b.Stats.Done()
+
+			if b.WorkerGoCache != "" {
+				opts.GoCache = filepath.Join(
+					b.WorkerGoCache, fmt.Sprintf("worker-%d", worker))
+			}
+			if opts.GoToolchain != "" {
+				fmt.Printf("--> %15s: %s (%s)\n", opts.Platform.String(), opts.PackagePath, opts.GoVersion)
+			} else {
+				fmt.Printf("--> %15s: %s\n", opts.Platform.String(), opts.PackagePath)
+			}
+			if b.OnStart != nil {
+				b.OnStart(opts)
+			}
+
+			buildCtx := ctx
+			if b.Timeout > 0 {
+				var cancel context.CancelFunc
+				buildCtx, cancel = context.WithTimeout(ctx, b.Timeout)
+				defer cancel()
+			}
+			opts.ctx = buildCtx
+
+			result.Err = compile(opts)
+			if result.Err == nil {
+				result.Output, result.Err = opts.OutputPath()
+				result.Artifacts = []string{result.Output}
+			}
+			if result.Err == nil && b.PostBuild != nil {
+				result.Err = b.PostBuild(opts, &result)
+			}
+			switch {
+			case result.Err == nil:
+				result.Status = StatusSuccess
+				if info, err := os.Stat(result.Output); err == nil {
+					result.Size = info.Size()
+				}
+			case b.cancelled():
+				result.Status, result.Err = StatusCancelled, errBuildCancelled
+			case buildCtx.Err() == context.DeadlineExceeded:
+				result.Status = StatusTimedOut
+				result.Err = fmt.Errorf("timed out after %s", b.Timeout)
+			default:
+				result.Status = StatusFailed
+			}
+			finish(result)
+		}(opts)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// cancelled reports whether Cancel is closed.
+func (b *Builder) cancelled() bool {
+	select {
+	case <-b.Cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// BuildHostFirst builds the packages for the host platform before any
+// other platform, since most compile errors aren't specific to a
+// platform. If a host build fails, the other platforms aren't built: the
+// failed host builds are returned along with a StatusSkipped result for
+// every other build.
+//
+// If the host platform isn't one of the platforms of jobs, the packages
+// are built for it in a temporary directory only as a check, and the
+// check builds aren't part of the results unless they fail.
+func (b *Builder) BuildHostFirst(jobs []*CompileOpts) []BuildResult {
+	var host, rest []*CompileOpts
+	for _, opts := range jobs {
+		if opts.Platform.OS == runtime.GOOS && opts.Platform.Arch == runtime.GOARCH {
+			host = append(host, opts)
+		} else {
+			rest = append(rest, opts)
+		}
+	}
+
+	var results []BuildResult
+	if len(host) > 0 {
+		results = b.Build(host)
+	} else {
+		check, err := b.checkHost(jobs)
+		if err != nil {
+			host := Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+			return append([]BuildResult{{Platform: host, Status: StatusFailed, Err: err}}, skipped(rest)...)
+		}
+
+		results = check
+	}
+
+	// A cancelled run doesn't skip the rest, which is cancelled too
+	if b.cancelled() {
+		if len(host) == 0 {
+			results = nil
+		}
+		return append(results, b.Build(rest)...)
+	}
+
+	var failed []BuildResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		if len(host) == 0 {
+			return append(failed, skipped(rest)...)
+		}
+
+		// Host builds that succeeded are kept, only the rest is skipped
+		return append(results, skipped(rest)...)
+	}
+
+	if len(host) == 0 {
+		results = nil
+	}
+
+	return append(results, b.Build(rest)...)
+}
+
+// skipped returns a StatusSkipped result for each of the jobs.
+func skipped(jobs []*CompileOpts) []BuildResult {
+	results := make([]BuildResult, 0, len(jobs))
+	for _, opts := range jobs {
+		results = append(results, BuildResult{
+			Platform:  opts.Platform,
+			Package:   opts.PackagePath,
+			GoVersion: opts.GoVersion,
+			Cover:     opts.Cover,
+			Status:    StatusSkipped,
+		})
+	}
+
+	return results
+}
+
+// checkHost builds each package of jobs for the host platform in a
+// temporary directory, without post-processing.
+func (b *Builder) checkHost(jobs []*CompileOpts) ([]BuildResult, error) {
+	dir, err := ioutil.TempDir("", "gox-host")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	seen := make(map[string]bool)
+	var check []*CompileOpts
+	for _, opts := range jobs {
+		if seen[opts.PackagePath] {
+			continue
+		}
+		seen[opts.PackagePath] = true
+
+		c := new(CompileOpts)
+		*c = *opts
+		c.Platform = Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+		c.OutputTpl = filepath.Join(dir, fmt.Sprintf("%d", len(check)), "{{.Dir}}")
+		c.OutputName = ""
+		c.NameSuffix = ""
+		c.LogFile = ""
+		check = append(check, c)
+	}
+
+	checker := &Builder{
+		Parallel:      b.Parallel,
+		WorkerGoCache: b.WorkerGoCache,
+		Cancel:        b.Cancel,
+		Timeout:       b.Timeout,
+		Stats:         b.Stats,
+		compile:       b.compile,
+	}
+
+	return checker.Build(check), nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBuilderCallbacks(t *testing.T) {
+	jobs := []*CompileOpts{
+		{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "linux", Arch: "amd64"},
+			OutputTpl:   "{{.Dir}}_{{.OS}}_{{.Arch}}",
+		},
+		{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "windows", Arch: "amd64"},
+			OutputTpl:   "{{.Dir}}_{{.OS}}_{{.Arch}}",
+		},
+	}
+
+	var lock sync.Mutex
+	var calls []string
+	record := func(s string) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls = append(calls, s)
+	}
+
+	b := &Builder{
+		Parallel: 1,
+		PostBuild: func(opts *CompileOpts, result *BuildResult) error {
+			record("post " + result.Platform.String())
+			result.Artifacts = append(result.Artifacts, "extra")
+			return nil
+		},
+		OnResult: func(result BuildResult) {
+			record("result " + result.Platform.String())
+			if result.Platform.OS == "linux" && len(result.Artifacts) != 2 {
+				t.Errorf("bad: %#v", result.Artifacts)
+			}
+		},
+		compile: func(opts *CompileOpts) error {
+			if opts.Platform.OS == "windows" {
+				return errors.New("failed")
+			}
+			return nil
+		},
+	}
+
+	results := b.Build(jobs)
+	if len(results) != 2 {
+		t.Fatalf("bad: %#v", results)
+	}
+	for _, r := range results {
+		expected := StatusSuccess
+		if r.Platform.OS == "windows" {
+			expected = StatusFailed
+		}
+		if r.Status != expected {
+			t.Fatalf("bad: %#v", r)
+		}
+	}
+
+	// PostBuild only runs for successful builds, OnResult runs after it
+	// for every build.
+	lock.Lock()
+	defer lock.Unlock()
+	expected := map[string]bool{
+		"post linux/amd64":     true,
+		"result linux/amd64":   true,
+		"result windows/amd64": true,
+	}
+	if len(calls) != len(expected) {
+		t.Fatalf("bad: %#v", calls)
+	}
+	for i, c := range calls {
+		if !expected[c] {
+			t.Fatalf("bad: %#v", calls)
+		}
+		if c == "result linux/amd64" && (i == 0 || calls[i-1] != "post linux/amd64") {
+			t.Fatalf("bad order: %#v", calls)
+		}
+	}
+}
+
+func TestBuilderBuildHostFirst(t *testing.T) {
+	host := Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	other := Platform{OS: "plan9", Arch: "386"}
+	if host == other {
+		other.Arch = "amd64"
+	}
+
+	jobs := func() []*CompileOpts {
+		return []*CompileOpts{
+			{PackagePath: "foo", Platform: other, OutputTpl: "{{.Dir}}_{{.OS}}_{{.Arch}}"},
+			{PackagePath: "foo", Platform: host, OutputTpl: "{{.Dir}}_{{.OS}}_{{.Arch}}"},
+		}
+	}
+
+	// A failed host build stops everything else
+	var lock sync.Mutex
+	var built []string
+	b := &Builder{
+		Parallel: 2,
+		compile: func(opts *CompileOpts) error {
+			lock.Lock()
+			defer lock.Unlock()
+			built = append(built, opts.Platform.String())
+			return errors.New("failed")
+		},
+	}
+	results := b.BuildHostFirst(jobs())
+	if len(results) != 2 ||
+		results[0].Platform != host || results[0].Status != StatusFailed ||
+		results[1].Platform != other || results[1].Status != StatusSkipped || results[1].Err != nil {
+		t.Fatalf("bad: %#v", results)
+	}
+	if len(built) != 1 {
+		t.Fatalf("bad: %#v", built)
+	}
+
+	// Otherwise the host is built before the rest
+	built = nil
+	b = &Builder{
+		Parallel: 2,
+		compile: func(opts *CompileOpts) error {
+			lock.Lock()
+			defer lock.Unlock()
+			built = append(built, opts.Platform.String())
+			return nil
+		},
+	}
+	results = b.BuildHostFirst(jobs())
+	if len(results) != 2 {
+		t.Fatalf("bad: %#v", results)
+	}
+	if !reflect.DeepEqual(built, []string{host.String(), other.String()}) {
+		t.Fatalf("bad: %#v", built)
+	}
+
+	// Without the host in the matrix, it is only built as a check
+	built = nil
+	results = b.BuildHostFirst(jobs()[:1])
+	if len(results) != 1 || results[0].Platform != other {
+		t.Fatalf("bad: %#v", results)
+	}
+	if !reflect.DeepEqual(built, []string{host.String(), other.String()}) {
+		t.Fatalf("bad: %#v", built)
+	}
+}
+
+func TestBuilderPostBuildConcurrent(t *testing.T) {
+	jobs := []*CompileOpts{
+		{PackagePath: "foo", Platform: Platform{OS: "linux", Arch: "amd64"}, OutputTpl: "{{.Dir}}_{{.OS}}"},
+		{PackagePath: "foo", Platform: Platform{OS: "windows", Arch: "amd64"}, OutputTpl: "{{.Dir}}_{{.OS}}"},
+	}
+
+	// Each post-build step waits until the other one has started, which
+	// only finishes if they run at the same time.
+	started := map[string]chan struct{}{
+		"linux":   make(chan struct{}),
+		"windows": make(chan struct{}),
+	}
+	other := map[string]string{"linux": "windows", "windows": "linux"}
+
+	b := &Builder{
+		Parallel: 2,
+		PostBuild: func(opts *CompileOpts, result *BuildResult) error {
+			close(started[opts.Platform.OS])
+			select {
+			case <-started[other[opts.Platform.OS]]:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("post-build steps ran serially")
+			}
+		},
+		compile: func(*CompileOpts) error { return nil },
+	}
+
+	for _, r := range b.Build(jobs) {
+		if r.Err != nil {
+			t.Fatalf("%s: %s", r.Platform.String(), r.Err)
+		}
+	}
+}
+
+func TestBuilderCancel(t *testing.T) {
+	jobs := []*CompileOpts{
+		{PackagePath: "foo", Platform: Platform{OS: "linux", Arch: "amd64"}, OutputTpl: "{{.Dir}}_{{.OS}}"},
+		{PackagePath: "foo", Platform: Platform{OS: "windows", Arch: "amd64"}, OutputTpl: "{{.Dir}}_{{.OS}}"},
+		{PackagePath: "foo", Platform: Platform{OS: "darwin", Arch: "amd64"}, OutputTpl: "{{.Dir}}_{{.OS}}"},
+	}
+
+	// The first build cancels the run and waits to be killed, so the
+	// builds queued behind it never start
+	cancel := make(chan struct{})
+	var lock sync.Mutex
+	var compiled, reported int
+	b := &Builder{
+		Parallel: 1,
+		Cancel:   cancel,
+		OnResult: func(BuildResult) {
+			lock.Lock()
+			defer lock.Unlock()
+			reported++
+		},
+		compile: func(opts *CompileOpts) error {
+			lock.Lock()
+			compiled++
+			lock.Unlock()
+
+			close(cancel)
+			select {
+			case <-opts.context().Done():
+				return opts.context().Err()
+			case <-time.After(5 * time.Second):
+				return errors.New("wasn't killed")
+			}
+		},
+	}
+
+	results := b.Build(jobs)
+	if len(results) != 3 || compiled != 1 || reported != 3 {
+		t.Fatalf("bad: %d compiled, %d reported, %#v", compiled, reported, results)
+	}
+	for _, r := range results {
+		if r.Status != StatusCancelled || r.Err == nil {
+			t.Fatalf("bad: %#v", r)
+		}
+	}
+}
+
+func TestBuilderTimeout(t *testing.T) {
+	jobs := []*CompileOpts{
+		{PackagePath: "foo", Platform: Platform{OS: "linux", Arch: "amd64"}, OutputTpl: "{{.Dir}}_{{.OS}}"},
+		{PackagePath: "foo", Platform: Platform{OS: "windows", Arch: "amd64"}, OutputTpl: "{{.Dir}}_{{.OS}}"},
+	}
+
+	// Only the linux build takes longer than the timeout
+	b := &Builder{
+		Parallel: 2,
+		Timeout:  50 * time.Millisecond,
+		compile: func(opts *CompileOpts) error {
+			if opts.Platform.OS != "linux" {
+				return nil
+			}
+			select {
+			case <-opts.context().Done():
+				return opts.context().Err()
+			case <-time.After(5 * time.Second):
+				return errors.New("wasn't killed")
+			}
+		},
+	}
+
+	for _, r := range b.Build(jobs) {
+		expected := StatusSuccess
+		if r.Platform.OS == "linux" {
+			expected = StatusTimedOut
+		}
+		if r.Status != expected {
+			t.Fatalf("bad: %#v", r)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checksumFiles are the files written for each format of -checksum-format.
+var checksumFiles = map[string]string{
+	"sha256": "checksums.txt",
+	"sha512": "checksums.sha512",
+	"json":   "checksums.json",
+}
+
+// artifactChecksum holds the digests of an artifact, in hex. Only the
+// digests of the requested formats are set.
+type artifactChecksum struct {
+	Name   string `json:"-"`
+	SHA256 string `json:"sha256,omitempty"`
+	SHA512 string `json:"sha512,omitempty"`
+}
+
+// artifactDigests are the SHA-256 digests, in hex, of artifacts by their
+// absolute path, as computed for the checksum files, so that later steps
+// don't read the artifacts again.
+type artifactDigests map[string]string
+
+// sha256 returns the SHA-256 digest of the artifact at path, hashing it
+// only if it isn't known yet.
+func (d artifactDigests) sha256(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if sum, ok := d[abs]; ok {
+		return sum, nil
+	}
+
+	return fileSHA256(path)
+}
+
+// parseChecksumFormats splits the value of -checksum-format, a list of
+// formats separated by commas or spaces.
+func parseChecksumFormats(s string) ([]string, error) {
+	formats := strings.Fields(strings.Replace(s, ",", " ", -1))
+	for _, f := range formats {
+		if _, ok := checksumFiles[f]; !ok {
+			return nil, fmt.Errorf("unknown checksum format %q, it should be sha256, sha512 or json", f)
+		}
+	}
+
+	return formats, nil
+}
+
+// writeChecksums writes a checksums file to dir for each of the formats,
+// covering the artifacts of the successful builds, and returns their
+// paths. Artifacts are named by their path relative to dir, which is
+// their file name if they were collected there. The sha256 and sha512
+// files are in the format of sha256sum and sha512sum, and the json file
+// maps names to their digests. The SHA-256 digests are added to digests,
+// if it isn't nil.
+func writeChecksums(dir string, formats []string, results []BuildResult, digests artifactDigests) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON has both digests, the others only their own
+	want := make(map[string]bool)
+	for _, f := range formats {
+		if f == "json" {
+			want["sha256"], want["sha512"] = true, true
+		} else {
+			want[f] = true
+		}
+	}
+
+	var sums []artifactChecksum
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+
+		for _, a := range r.Artifacts {
+			sum, err := checksumFile(a, want["sha256"], want["sha512"])
+			if err != nil {
+				return nil, err
+			}
+
+			sum.Name = a
+			if abs, err := filepath.Abs(a); err == nil {
+				if rel, err := filepath.Rel(dir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+					sum.Name = rel
+				}
+				if digests != nil && sum.SHA256 != "" {
+					digests[abs] = sum.SHA256
+				}
+			}
+			sum.Name = filepath.ToSlash(sum.Name)
+			sums = append(sums, sum)
+		}
+	}
+	sort.Slice(sums, func(i, j int) bool {
+		return sums[i].Name < sums[j].Name
+	})
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, f := range formats {
+		var data []byte
+		switch f {
+		case "json":
+			byName := make(map[string]artifactChecksum, len(sums))
+			for _, s := range sums {
+				byName[s.Name] = s
+			}
+			data, err = json.MarshalIndent(byName, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, '\n')
+		default:
+			var b strings.Builder
+			for _, s := range sums {
+				digest := s.SHA256
+				if f == "sha512" {
+					digest = s.SHA512
+				}
+				fmt.Fprintf(&b, "%s  %s\n", digest, s.Name)
+			}
+			data = []byte(b.String())
+		}
+
+		path := filepath.Join(dir, checksumFiles[f])
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// checksumFile computes the requested digests of the file at path in a
+// single read.
+func checksumFile(path string, sha256Sum, sha512Sum bool) (artifactChecksum, error) {
+	var result artifactChecksum
+	f, err := os.Open(path)
+	if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	var writers []io.Writer
+	var h256, h512 hash.Hash
+	if sha256Sum {
+		h256 = sha256.New()
+		writers = append(writers, h256)
+	}
+	if sha512Sum {
+		h512 = sha512.New()
+		writers = append(writers, h512)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return result, err
+	}
+
+	if h256 != nil {
+		result.SHA256 = hex.EncodeToString(h256.Sum(nil))
+	}
+	if h512 != nil {
+		result.SHA512 = hex.EncodeToString(h512.Sum(nil))
+	}
+
+	return result, nil
+}
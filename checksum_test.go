@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseChecksumFormats(t *testing.T) {
+	formats, err := parseChecksumFormats("sha256,json")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(formats, []string{"sha256", "json"}) {
+		t.Fatalf("bad: %#v", formats)
+	}
+
+	if _, err := parseChecksumFormats("b2"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestWriteChecksums(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var results []BuildResult
+	for _, name := range []string{"b", "a"} {
+		path := filepath.Join(td, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		results = append(results, BuildResult{Artifacts: []string{path}})
+	}
+	results = append(results, BuildResult{Artifacts: []string{"missing"}, Err: errors.New("failed")})
+
+	digests := make(artifactDigests)
+	paths, err := writeChecksums(td, []string{"sha256", "sha512", "json"}, results, digests)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expectedPaths := []string{
+		filepath.Join(td, "checksums.txt"),
+		filepath.Join(td, "checksums.sha512"),
+		filepath.Join(td, "checksums.json"),
+	}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Fatalf("bad: %#v", paths)
+	}
+	if len(digests) != 2 || digests[filepath.Join(td, "a")] != fmt.Sprintf("%x", sha256.Sum256([]byte("a"))) {
+		t.Fatalf("bad: %#v", digests)
+	}
+
+	sha256Hex := func(s string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(s))) }
+	sha512Hex := func(s string) string { return fmt.Sprintf("%x", sha512.Sum512([]byte(s))) }
+	expected := map[string]string{
+		"checksums.txt":    sha256Hex("a") + "  a\n" + sha256Hex("b") + "  b\n",
+		"checksums.sha512": sha512Hex("a") + "  a\n" + sha512Hex("b") + "  b\n",
+		"checksums.json": `{
+  "a": {
+    "sha256": "` + sha256Hex("a") + `",
+    "sha512": "` + sha512Hex("a") + `"
+  },
+  "b": {
+    "sha256": "` + sha256Hex("b") + `",
+    "sha512": "` + sha512Hex("b") + `"
+  }
+}
+`,
+	}
+	for name, contents := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(td, name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(data) != contents {
+			t.Fatalf("%s: bad:\n%s", name, data)
+		}
+	}
+}
+
+func TestChecksumFile_onlyRequested(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "a")
+	if err := ioutil.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sum, err := checksumFile(path, true, false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sum.SHA256 == "" || sum.SHA512 != "" {
+		t.Fatalf("bad: %#v", sum)
+	}
+}
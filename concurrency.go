@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// poolStats records how busy the pool of build workers was over a run.
+// It is cheap enough to be updated from every worker without affecting
+// build throughput.
+type poolStats struct {
+	size int
+	now  func() time.Time
+
+	mu        sync.Mutex
+	start     time.Time
+	last      time.Time
+	active    int
+	maxActive int
+	busy      time.Duration
+}
+
+func newPoolStats(size int) *poolStats {
+	s := &poolStats{size: size, now: time.Now}
+	s.start = s.now()
+	s.last = s.start
+	return s
+}
+
+// Start records that a worker began a build.
+func (s *poolStats) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	s.active++
+	if s.active > s.maxActive {
+		s.maxActive = s.active
+	}
+}
+
+// Done records that a worker finished a build.
+func (s *poolStats) Done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	s.active--
+}
+
+// advance accumulates busy worker-time up to now. The lock must be held.
+func (s *poolStats) advance() {
+	now := s.now()
+	s.busy += time.Duration(s.active) * now.Sub(s.last)
+	s.last = now
+}
+
+// Report writes a human readable summary of the pool utilization.
+func (s *poolStats) Report(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+
+	wall := s.last.Sub(s.start)
+	capacity := time.Duration(s.size) * wall
+	var average, utilization float64
+	if wall > 0 {
+		average = float64(s.busy) / float64(wall)
+		utilization = 100 * float64(s.busy) / float64(capacity)
+	}
+
+	fmt.Fprintf(w, "\nConcurrency report:\n")
+	fmt.Fprintf(w, "  Workers:                %d\n", s.size)
+	fmt.Fprintf(w, "  Wall time:              %s\n", wall.Round(time.Millisecond))
+	fmt.Fprintf(w, "  Max concurrent builds:  %d\n", s.maxActive)
+	fmt.Fprintf(w, "  Avg concurrent builds:  %.2f\n", average)
+	fmt.Fprintf(w, "  Busy worker-time:       %s\n", s.busy.Round(time.Millisecond))
+	fmt.Fprintf(w, "  Idle worker-time:       %s\n", (capacity - s.busy).Round(time.Millisecond))
+	fmt.Fprintf(w, "  Utilization:            %.1f%%\n", utilization)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoolStats(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := newPoolStats(2)
+	s.now = func() time.Time { return now }
+	s.start, s.last = now, now
+
+	// Two builds overlap for one second, then one runs alone for one more.
+	s.Start()
+	s.Start()
+	now = now.Add(time.Second)
+	s.Done()
+	now = now.Add(time.Second)
+	s.Done()
+
+	if s.maxActive != 2 {
+		t.Fatalf("bad max: %d", s.maxActive)
+	}
+	if s.busy != 3*time.Second {
+		t.Fatalf("bad busy: %s", s.busy)
+	}
+
+	var buf bytes.Buffer
+	s.Report(&buf)
+	for _, expected := range []string{
+		"Avg concurrent builds:  1.50",
+		"Idle worker-time:       1s",
+		"Utilization:            75.0%",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("missing %q in:\n%s", expected, buf.String())
+		}
+	}
+}
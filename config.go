@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is the config file read from the current directory
+// if -config isn't given.
+const defaultConfigFile = ".gox.yml"
+
+// Config is a gox config file. Its settings are flag values keyed by the
+// flag name without the dash, for example:
+//
+//	osarch: linux/amd64 darwin/arm64
+//	ldflags: -s -w
+//	profiles:
+//	  dev:
+//	    only: linux/amd64
+//	  release:
+//	    all: true
+//
+// A list sets a flag once per item, for flags that may be repeated.
+type Config struct {
+	// Settings are the base settings, used with or without a profile.
+	Settings map[string][]string
+
+	// Profiles are named sets of settings that override the base ones.
+	Profiles map[string]map[string][]string
+}
+
+// loadConfig reads the config file at path.
+func loadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	c := &Config{Profiles: make(map[string]map[string][]string)}
+	if profiles, ok := raw["profiles"]; ok {
+		delete(raw, "profiles")
+		m, ok := profiles.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: profiles must be a map of profile names to settings", path)
+		}
+
+		for name, settings := range m {
+			sm, ok := settings.(map[string]interface{})
+			if !ok && settings != nil {
+				return nil, fmt.Errorf("%s: profile %s must be a map of settings", path, name)
+			}
+
+			if c.Profiles[name], err = configSettings(sm); err != nil {
+				return nil, fmt.Errorf("%s: profile %s: %s", path, name, err)
+			}
+		}
+	}
+
+	if c.Settings, err = configSettings(raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return c, nil
+}
+
+// configSettings converts the raw values of settings to flag values.
+func configSettings(raw map[string]interface{}) (map[string][]string, error) {
+	result := make(map[string][]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case []interface{}:
+			values := make([]string, 0, len(v))
+			for _, item := range v {
+				s, err := configValue(k, item)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, s)
+			}
+			result[k] = values
+		default:
+			s, err := configValue(k, v)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = []string{s}
+		}
+	}
+
+	return result, nil
+}
+
+func configValue(key string, v interface{}) (string, error) {
+	switch v.(type) {
+	case string, bool, int, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("%s must be a string, number, boolean or list of them", key)
+	}
+}
+
+// Resolve returns the settings of the named profile merged over the base
+// settings. An empty profile returns the base settings.
+func (c *Config) Resolve(profile string) (map[string][]string, error) {
+	result := make(map[string][]string, len(c.Settings))
+	for k, v := range c.Settings {
+		result[k] = v
+	}
+
+	if profile == "" {
+		return result, nil
+	}
+
+	settings, ok := c.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", profile)
+	}
+	for k, v := range settings {
+		result[k] = v
+	}
+
+	return result, nil
+}
+
+// platformSettings are the settings that select platforms. They combine
+// with, or conflict with, each other, so if any of them is given on the
+// command line, those of the config are all ignored.
+var platformSettings = []string{"os", "arch", "osarch", "all", "only", "recent-platforms"}
+
+// applyConfig sets the flags of settings that weren't given on the
+// command line, so that the command line always wins.
+func applyConfig(flags *flag.FlagSet, settings map[string][]string) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, k := range platformSettings {
+		if set[k] {
+			for _, p := range platformSettings {
+				set[p] = true
+			}
+			break
+		}
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "config" || k == "profile" || flags.Lookup(k) == nil {
+			return fmt.Errorf("%s is not a setting", k)
+		}
+		if set[k] {
+			continue
+		}
+
+		for _, v := range settings[k] {
+			if err := flags.Set(k, v); err != nil {
+				return fmt.Errorf("%s: %s", k, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testConfig = `
+ldflags: -s -w
+tags: base
+parallel: 4
+osarch:
+  - linux/amd64
+  - darwin/arm64
+profiles:
+  dev:
+    only: linux/amd64
+    osarch: []
+  release:
+    tags: release
+    trimpath: true
+`
+
+func TestLoadConfig(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, ".gox.yml")
+	if err := ioutil.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	base, err := c.Resolve("")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string][]string{
+		"ldflags":  {"-s -w"},
+		"tags":     {"base"},
+		"parallel": {"4"},
+		"osarch":   {"linux/amd64", "darwin/arm64"},
+	}
+	if !reflect.DeepEqual(base, expected) {
+		t.Fatalf("bad: %#v", base)
+	}
+
+	release, err := c.Resolve("release")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected["tags"] = []string{"release"}
+	expected["trimpath"] = []string{"true"}
+	if !reflect.DeepEqual(release, expected) {
+		t.Fatalf("bad: %#v", release)
+	}
+
+	if _, err := c.Resolve("bogus"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	var ldflags, tags string
+	var trimpath bool
+	var osarch PlatformFlag
+	flags := flag.NewFlagSet("gox", flag.ContinueOnError)
+	flags.StringVar(&ldflags, "ldflags", "", "")
+	flags.StringVar(&tags, "tags", "", "")
+	flags.BoolVar(&trimpath, "trimpath", false, "")
+	flags.Var(osarch.OSArchFlagValue(), "osarch", "")
+	if err := flags.Parse([]string{"-tags", "cli"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := applyConfig(flags, map[string][]string{
+		"ldflags":  {"-s -w"},
+		"tags":     {"release"},
+		"trimpath": {"true"},
+		"osarch":   {"linux/amd64", "darwin/arm64"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The command line wins over the config
+	if ldflags != "-s -w" || tags != "cli" || !trimpath {
+		t.Fatalf("bad: %q %q %v", ldflags, tags, trimpath)
+	}
+	if len(osarch.OSArch) != 2 {
+		t.Fatalf("bad: %#v", osarch.OSArch)
+	}
+
+	if err := applyConfig(flags, map[string][]string{"bogus": {"1"}}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestApplyConfig_platforms(t *testing.T) {
+	var platformFlag PlatformFlag
+	var only string
+	flags := flag.NewFlagSet("gox", flag.ContinueOnError)
+	flags.Var(platformFlag.OSFlagValue(), "os", "")
+	flags.Var(platformFlag.ArchFlagValue(), "arch", "")
+	flags.Var(platformFlag.OSArchFlagValue(), "osarch", "")
+	flags.BoolVar(&platformFlag.All, "all", false, "")
+	flags.StringVar(&only, "only", "", "")
+	if err := flags.Parse([]string{"-only", "linux/amd64"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// -only on the command line replaces the platforms of the config,
+	// which it would otherwise conflict with
+	err := applyConfig(flags, map[string][]string{
+		"osarch": {"darwin/arm64"},
+		"os":     {"windows"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(platformFlag.OSArch) != 0 || len(platformFlag.OS) != 0 {
+		t.Fatalf("bad: %#v", platformFlag)
+	}
+
+	platforms, err := platformFlag.Only(only, []Platform{{OS: "linux", Arch: "amd64"}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(platforms) != 1 || platforms[0].String() != "linux/amd64" {
+		t.Fatalf("bad: %#v", platforms)
+	}
+}
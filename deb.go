@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// writeDeb writes p as a Debian package to path. A .deb is an ar archive
+// of the format version, a tarball of the control files and a tarball of
+// the files to install.
+func writeDeb(path string, p linuxPackage) error {
+	binary, err := ioutil.ReadFile(p.Binary)
+	if err != nil {
+		return err
+	}
+
+	control := fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\n", p.Name, p.Version, p.Platform.DebianArch())
+	if p.Maintainer != "" {
+		control += fmt.Sprintf("Maintainer: %s\n", p.Maintainer)
+	}
+	control += fmt.Sprintf("Installed-Size: %d\nSection: utils\nPriority: optional\n", (len(binary)+1023)/1024)
+	control += "Description: " + debDescription(p.Description) + "\n"
+
+	installPath := "usr/bin/" + p.Name
+	md5sums := fmt.Sprintf("%x  %s\n", md5.Sum(binary), installPath)
+
+	controlTar, err := debTarGz(p.ModTime, []debTarEntry{
+		{Name: "./control", Mode: 0644, Data: []byte(control)},
+		{Name: "./md5sums", Mode: 0644, Data: []byte(md5sums)},
+	})
+	if err != nil {
+		return err
+	}
+
+	dataTar, err := debTarGz(p.ModTime, []debTarEntry{
+		{Name: "./", Mode: 0755, Dir: true},
+		{Name: "./usr/", Mode: 0755, Dir: true},
+		{Name: "./usr/bin/", Mode: 0755, Dir: true},
+		{Name: "./" + installPath, Mode: int64(p.binaryMode()), Data: binary},
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", controlTar},
+		{"data.tar.gz", dataTar},
+	} {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n",
+			m.name, p.ModTime.Unix(), 0, 0, 0100644, len(m.data))
+		buf.Write(m.data)
+		if len(m.data)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// debDescription formats a description for the Description field of a
+// control file: the first line is the synopsis, and the others are
+// indented, with empty lines written as ".".
+func debDescription(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			lines[i] = " ."
+		} else {
+			lines[i] = " " + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// debTarEntry is a file or directory of a tarball in a Debian package.
+type debTarEntry struct {
+	Name string
+	Mode int64
+	Dir  bool
+	Data []byte
+}
+
+// debTarGz returns a gzipped tarball of the entries, owned by root and
+// all modified at modTime.
+func debTarGz(modTime time.Time, entries []debTarEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:    e.Name,
+			Mode:    e.Mode,
+			Size:    int64(len(e.Data)),
+			ModTime: modTime,
+			Uname:   "root",
+			Gname:   "root",
+			Format:  tar.FormatGNU,
+		}
+		if e.Dir {
+			hdr.Typeflag = tar.TypeDir
+		} else {
+			hdr.Typeflag = tar.TypeReg
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(e.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDeb(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	binary := filepath.Join(td, "app")
+	if err := ioutil.WriteFile(binary, []byte("odd"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := linuxPackage{
+		Name:        "app",
+		Version:     "1.2.0",
+		Maintainer:  "Jane Doe <jane@example.com>",
+		Description: "An app\n\nThat does things.",
+		Platform:    Platform{OS: "linux", Arch: "arm64"},
+		Mode:        0750,
+		Binary:      binary,
+		ModTime:     time.Unix(1700000000, 0),
+	}
+	path := filepath.Join(td, "app.deb")
+	if err := writeDeb(path, p); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.HasPrefix(data, []byte("!<arch>\n")) {
+		t.Fatalf("bad: %q", data[:8])
+	}
+
+	members := make(map[string][]byte)
+	var names []string
+	for rest := data[8:]; len(rest) > 0; {
+		name := strings.TrimSpace(string(rest[:16]))
+		size, err := strconv.Atoi(strings.TrimSpace(string(rest[48:58])))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if mtime := strings.TrimSpace(string(rest[16:28])); mtime != "1700000000" {
+			t.Fatalf("bad mtime: %s", mtime)
+		}
+
+		names = append(names, name)
+		members[name] = rest[60 : 60+size]
+		rest = rest[60+size+size%2:]
+	}
+	if strings.Join(names, " ") != "debian-binary control.tar.gz data.tar.gz" {
+		t.Fatalf("bad: %v", names)
+	}
+	if string(members["debian-binary"]) != "2.0\n" {
+		t.Fatalf("bad: %q", members["debian-binary"])
+	}
+
+	control := readTarGz(t, members["control.tar.gz"])
+	expected := `Package: app
+Version: 1.2.0
+Architecture: arm64
+Maintainer: Jane Doe <jane@example.com>
+Installed-Size: 1
+Section: utils
+Priority: optional
+Description: An app
+ .
+ That does things.
+`
+	if control["./control"] != expected {
+		t.Fatalf("bad:\n%s", control["./control"])
+	}
+
+	files := readTarGz(t, members["data.tar.gz"])
+	if files["./usr/bin/app"] != "odd" {
+		t.Fatalf("bad: %#v", files)
+	}
+	if modes := tarGzModes(t, members["data.tar.gz"]); modes["./usr/bin/app"] != 0750 {
+		t.Fatalf("bad mode: %o", modes["./usr/bin/app"])
+	}
+}
+
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		result[hdr.Name] = string(contents)
+	}
+
+	return result
+}
+
+func tarGzModes(t *testing.T, data []byte) map[string]int64 {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result := make(map[string]int64)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		result[hdr.Name] = hdr.Mode
+	}
+
+	return result
+}
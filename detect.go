@@ -0,0 +1,30 @@
+package main
+
+// Detected is what gox otherwise detects at the start of every run: the
+// version of Go on the PATH and the platforms to build. Programs that run
+// gox many times can detect once with Detect and pass the result to
+// realMain, skipping the go commands it runs to find out.
+type Detected struct {
+	// GoVersion is the version of the go command, such as "go1.21.4".
+	GoVersion string
+
+	// Platforms are the platforms to build, as is. The flags that select
+	// platforms are ignored when they are given.
+	Platforms []Platform
+}
+
+// Detect returns the version of Go on the PATH and the platforms gox
+// builds by default with it, following a newer toolchain required by the
+// go.mod or go.work of dir.
+func Detect(dir string) (*Detected, error) {
+	v, err := GoVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	supported := resolveSupportedPlatforms(effectiveGoVersion(v, dir, false), "", false)
+	return &Detected{
+		GoVersion: v,
+		Platforms: (&PlatformFlag{}).Platforms(supported),
+	}, nil
+}
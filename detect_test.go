@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	detected, err := Detect(".")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	v, err := GoVersion()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if detected.GoVersion != v {
+		t.Fatalf("bad: %s", detected.GoVersion)
+	}
+
+	// linux/amd64 is a first class port of every version of Go
+	found := false
+	for _, p := range detected.Platforms {
+		if p.String() == "linux/amd64" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("bad: %#v", detected.Platforms)
+	}
+}
+
+func TestRealMain_detected(t *testing.T) {
+	detected := &Detected{
+		GoVersion: "go1.21.4",
+		Platforms: []Platform{
+			{OS: "linux", Arch: "arm64"},
+			{OS: "windows", Arch: "amd64"},
+		},
+	}
+
+	// The flags that select platforms don't change the detected ones
+	cases := [][]string{
+		{"-osarch", "darwin/amd64"},
+		{"-only", "darwin/amd64"},
+		{"-recent-platforms", "1"},
+	}
+	for _, args := range cases {
+		var code int
+		output := captureStdout(t, func() {
+			code = realMain(append([]string{"-dry-run=json"}, append(args, ".")...), detected)
+		})
+		if code != 0 {
+			t.Fatalf("%v: exit code %d", args, code)
+		}
+
+		var plan buildPlan
+		if err := json.Unmarshal(output, &plan); err != nil {
+			t.Fatalf("%v: err: %s\n%s", args, err, output)
+		}
+		var platforms []string
+		for _, b := range plan.Builds {
+			if b.GoVersion != "go1.21.4" {
+				t.Fatalf("%v: bad Go version: %s", args, b.GoVersion)
+			}
+			platforms = append(platforms, b.Platform)
+		}
+		if !reflect.DeepEqual(platforms, []string{"linux/arm64", "windows/amd64"}) {
+			t.Fatalf("%v: bad: %v", args, platforms)
+		}
+	}
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) []byte {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		done <- data
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	f()
+	os.Stdout = stdout
+	w.Close()
+
+	return <-done
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DryRunFlag is the value of -dry-run: empty if it isn't set, "text" for
+// a plain -dry-run, or "json" for -dry-run=json.
+type DryRunFlag string
+
+// IsBoolFlag lets -dry-run be given without a value.
+func (f *DryRunFlag) IsBoolFlag() bool { return true }
+
+func (f *DryRunFlag) String() string { return string(*f) }
+
+func (f *DryRunFlag) Set(v string) error {
+	switch v {
+	case "true", "text":
+		*f = "text"
+	case "false":
+		*f = ""
+	case "json":
+		*f = "json"
+	default:
+		return fmt.Errorf("must be text or json")
+	}
+
+	return nil
+}
+
+// dryRunSchemaVersion is the version of the JSON of -dry-run=json. Fields
+// may be added without changing it; it changes only if fields are
+// removed or their meaning changes.
+const dryRunSchemaVersion = 1
+
+// buildPlan is the JSON written by -dry-run=json.
+type buildPlan struct {
+	Version int            `json:"version"`
+	Channel string         `json:"channel,omitempty"`
+	Builds  []plannedBuild `json:"builds"`
+
+	// ArtifactsDir is the -artifacts-dir the outputs are collected in, if
+	// any, and Checksums are the checksum files written once all builds
+	// are done.
+	ArtifactsDir string   `json:"artifacts_dir,omitempty"`
+	Checksums    []string `json:"checksums"`
+}
+
+// plannedBuild is a single go build of the plan.
+type plannedBuild struct {
+	Platform  string `json:"platform"`
+	Package   string `json:"package"`
+	GoVersion string `json:"go_version"`
+
+	// Dir is the directory the command runs in, the current directory
+	// if empty. Argv is the command, starting with the go command, and
+	// Env its complete environment.
+	Dir  string   `json:"dir,omitempty"`
+	Argv []string `json:"argv"`
+	Env  []string `json:"env"`
+
+	Output string `json:"output"`
+
+	// Steps are the post-processing steps run, in order, after a
+	// successful build, such as "winsign" or "package:deb".
+	Steps []string `json:"steps"`
+}
+
+// planOpts are the post-processing options of the run.
+type planOpts struct {
+	Channel        string
+	WinSign        bool
+	WasmOpt        bool
+	OutputMode     bool
+	PackageFormats []string
+	ArtifactsDir   string
+	Checksums      []string
+}
+
+// Steps returns the post-processing steps that run after building opts,
+// the way main adds them to postBuildSteps.
+func (p planOpts) Steps(opts *CompileOpts) []string {
+	steps := []string{}
+	if p.WinSign && opts.Platform.OS == "windows" {
+		steps = append(steps, "winsign")
+	}
+	if p.WasmOpt && opts.Platform.Arch == "wasm" {
+		steps = append(steps, "wasmopt")
+	}
+	if p.OutputMode {
+		steps = append(steps, "chmod")
+	}
+	for _, format := range p.PackageFormats {
+		if _, ok := packageArches[format]; ok && opts.Platform.OS != "linux" {
+			continue
+		}
+		steps = append(steps, "package:"+format)
+	}
+
+	return steps
+}
+
+// newBuildPlan returns the plan of the jobs, with the environment of each
+// build given by env, which is Platform.TargetEnv outside of tests.
+func newBuildPlan(jobs []*CompileOpts, p planOpts,
+	env func(opts *CompileOpts) []string) (*buildPlan, error) {
+	plan := &buildPlan{
+		Version:      dryRunSchemaVersion,
+		Channel:      p.Channel,
+		Builds:       []plannedBuild{},
+		ArtifactsDir: p.ArtifactsDir,
+		Checksums:    []string{},
+	}
+
+	for _, opts := range jobs {
+		args, err := opts.BuildArgs()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", opts.Platform.String(), err)
+		}
+		output, err := opts.OutputPath()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", opts.Platform.String(), err)
+		}
+		_, dir := opts.packageDir()
+
+		plan.Builds = append(plan.Builds, plannedBuild{
+			Platform:  opts.Platform.String(),
+			Package:   opts.PackagePath,
+			GoVersion: opts.GoVersion,
+			Dir:       dir,
+			Argv:      append([]string{opts.GoCmd}, args...),
+			Env:       env(opts),
+			Output:    output,
+			Steps:     p.Steps(opts),
+		})
+	}
+
+	dir := p.ArtifactsDir
+	if dir == "" {
+		dir = "."
+	}
+	for _, f := range p.Checksums {
+		plan.Checksums = append(plan.Checksums, filepath.Join(dir, checksumFiles[f]))
+	}
+	sort.Strings(plan.Checksums)
+
+	return plan, nil
+}
+
+// printBuildPlan writes the plan for people: for each build, the
+// variables gox sets and the command, followed by its steps.
+func printBuildPlan(w io.Writer, plan *buildPlan, jobs []*CompileOpts) {
+	for i, b := range plan.Builds {
+		fmt.Fprintf(w, "--> %15s: %s\n", b.Platform, b.Package)
+
+		// Only the variables gox sets, the rest is inherited
+		words := jobs[i].Platform.targetEnv(nil, jobs[i])
+		for _, arg := range b.Argv {
+			words = append(words, shellQuote(arg))
+		}
+		line := strings.Join(words, " ")
+		if b.Dir != "" {
+			line = "cd " + shellQuote(b.Dir) + " && " + line
+		}
+		fmt.Fprintf(w, "    %s\n", line)
+		if len(b.Steps) > 0 {
+			fmt.Fprintf(w, "    then: %s\n", strings.Join(b.Steps, ", "))
+		}
+	}
+
+	if len(plan.Checksums) > 0 {
+		fmt.Fprintf(w, "\nChecksums: %s\n", strings.Join(plan.Checksums, ", "))
+	}
+}
+
+// writeBuildPlan writes the plan as indented JSON.
+func writeBuildPlan(w io.Writer, plan *buildPlan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plan)
+}
+
+// shellQuote quotes s for a POSIX shell if it needs quoting.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./:,+@%") == "" {
+		return s
+	}
+
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDryRunFlag(t *testing.T) {
+	cases := map[string]DryRunFlag{
+		"-dry-run":       "text",
+		"-dry-run=json":  "json",
+		"-dry-run=false": "",
+	}
+	for arg, expected := range cases {
+		var f DryRunFlag
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.Var(&f, "dry-run", "")
+		if err := flags.Parse([]string{arg}); err != nil {
+			t.Fatalf("%s: err: %s", arg, err)
+		}
+		if f != expected {
+			t.Fatalf("%s: bad: %q", arg, f)
+		}
+	}
+
+	var f DryRunFlag
+	if err := f.Set("yaml"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestNewBuildPlan(t *testing.T) {
+	jobs := []*CompileOpts{
+		{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "linux", Arch: "amd64"},
+			OutputTpl:   "dist/{{.Dir}}_{{.OS}}_{{.Arch}}",
+			GoCmd:       "go",
+			GoVersion:   "go1.21.4",
+		},
+		{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "windows", Arch: "amd64"},
+			OutputTpl:   "dist/{{.Dir}}_{{.OS}}_{{.Arch}}",
+			GoCmd:       "go",
+			GoVersion:   "go1.21.4",
+		},
+	}
+	opts := planOpts{
+		WinSign:        true,
+		PackageFormats: []string{"deb", "tgz"},
+		ArtifactsDir:   "out",
+		Checksums:      []string{"sha256"},
+	}
+	env := func(opts *CompileOpts) []string {
+		return opts.Platform.targetEnv([]string{"HOME=/home/gopher"}, opts)
+	}
+
+	plan, err := newBuildPlan(jobs, opts, env)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(plan.Builds) != 2 {
+		t.Fatalf("bad: %#v", plan.Builds)
+	}
+	linux, windows := plan.Builds[0], plan.Builds[1]
+	if !strings.HasSuffix(linux.Output, "foo_linux_amd64") ||
+		!strings.HasSuffix(windows.Output, "foo_windows_amd64.exe") {
+		t.Fatalf("bad: %s %s", linux.Output, windows.Output)
+	}
+	if linux.Argv[0] != "go" || linux.Argv[1] != "build" {
+		t.Fatalf("bad: %#v", linux.Argv)
+	}
+	if linux.Env[0] != "HOME=/home/gopher" || linux.Env[1] != "GOOS=linux" {
+		t.Fatalf("bad: %#v", linux.Env)
+	}
+	if !reflect.DeepEqual(linux.Steps, []string{"package:deb", "package:tgz"}) {
+		t.Fatalf("bad: %#v", linux.Steps)
+	}
+	if !reflect.DeepEqual(windows.Steps, []string{"winsign", "package:tgz"}) {
+		t.Fatalf("bad: %#v", windows.Steps)
+	}
+	if !reflect.DeepEqual(plan.Checksums, []string{"out/checksums.txt"}) {
+		t.Fatalf("bad: %#v", plan.Checksums)
+	}
+
+	// The JSON is the documented schema
+	var buf bytes.Buffer
+	if err := writeBuildPlan(&buf, plan); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, key := range []string{"version", "builds", "artifacts_dir", "checksums"} {
+		if _, ok := decoded[key]; !ok {
+			t.Fatalf("missing %s: %s", key, buf.String())
+		}
+	}
+
+	buf.Reset()
+	printBuildPlan(&buf, plan, jobs)
+	if !strings.Contains(buf.String(), "GOOS=windows GOARCH=amd64 CGO_ENABLED=0 go build") {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"-ldflags":   "-ldflags",
+		"":           "''",
+		"-s -w":      "'-s -w'",
+		"it's":       `'it'"'"'s'`,
+		"GOOS=linux": "GOOS=linux",
+	}
+	for input, expected := range cases {
+		if actual := shellQuote(input); actual != expected {
+			t.Fatalf("%q: bad: %s", input, actual)
+		}
+	}
+}
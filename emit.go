@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// emitFormats are the package manager formats of -emit.
+var emitFormats = map[string]func(w io.Writer, pkg string, entries []emitEntry) error{
+	"brew":  emitBrew,
+	"scoop": emitScoop,
+}
+
+// emitEntry is the download of the artifact of a single build.
+type emitEntry struct {
+	Platform Platform
+	URL      string
+	SHA256   string
+}
+
+// emitPackages writes the downloads of the successful builds in the
+// given format, one section per package. The download of a build is its
+// first artifact, at baseURL followed by the file name of the artifact.
+func emitPackages(w io.Writer, format, baseURL string, results []BuildResult) error {
+	emit, ok := emitFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown -emit format %q", format)
+	}
+
+	byPackage := make(map[string][]emitEntry)
+	for _, r := range results {
+		if r.Err != nil || len(r.Artifacts) == 0 {
+			continue
+		}
+
+		sum, err := fileSHA256(r.Artifacts[0])
+		if err != nil {
+			return err
+		}
+
+		byPackage[r.Package] = append(byPackage[r.Package], emitEntry{
+			Platform: r.Platform,
+			URL:      strings.TrimSuffix(baseURL, "/") + "/" + filepath.Base(r.Artifacts[0]),
+			SHA256:   sum,
+		})
+	}
+
+	packages := make([]string, 0, len(byPackage))
+	for pkg := range byPackage {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+
+	for _, pkg := range packages {
+		entries := byPackage[pkg]
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Platform.String() < entries[j].Platform.String()
+		})
+
+		if err := emit(w, pkg, entries); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emitBrew writes the url and sha256 stanzas of a Homebrew formula for
+// the macOS and Linux builds on Intel and ARM.
+func emitBrew(w io.Writer, pkg string, entries []emitEntry) error {
+	fmt.Fprintf(w, "# %s\n", pkg)
+	for _, goos := range []string{"darwin", "linux"} {
+		var blocks []string
+		for _, e := range entries {
+			if e.Platform.OS != goos {
+				continue
+			}
+
+			var cpu string
+			switch e.Platform.Arch {
+			case "amd64":
+				cpu = "on_intel"
+			case "arm64":
+				cpu = "on_arm"
+			default:
+				continue
+			}
+
+			blocks = append(blocks, fmt.Sprintf(
+				"  %s do\n    url %q\n    sha256 %q\n  end\n", cpu, e.URL, e.SHA256))
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		block := "on_linux"
+		if goos == "darwin" {
+			block = "on_macos"
+		}
+		fmt.Fprintf(w, "%s do\n%send\n", block, strings.Join(blocks, ""))
+	}
+
+	return nil
+}
+
+// scoopArch maps GOARCH to the architectures of a Scoop manifest.
+var scoopArch = map[string]string{
+	"386":   "32bit",
+	"amd64": "64bit",
+	"arm64": "arm64",
+}
+
+type scoopDownload struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash"`
+}
+
+// emitScoop writes the architecture section of a Scoop manifest for the
+// windows builds.
+func emitScoop(w io.Writer, pkg string, entries []emitEntry) error {
+	arch := make(map[string]scoopDownload)
+	for _, e := range entries {
+		if e.Platform.OS != "windows" || scoopArch[e.Platform.Arch] == "" {
+			continue
+		}
+
+		arch[scoopArch[e.Platform.Arch]] = scoopDownload{URL: e.URL, Hash: e.SHA256}
+	}
+	if len(arch) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{"architecture": arch}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "# %s\n%s\n", pkg, data)
+	return err
+}
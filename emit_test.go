@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testEmitResults(t *testing.T, dir string) []BuildResult {
+	var results []BuildResult
+	for _, osarch := range []string{"darwin/amd64", "darwin/arm64", "linux/amd64", "linux/arm", "windows/amd64", "windows/386"} {
+		p := Platform{OS: osarch[:len(osarch)-len(filepath.Base(osarch))-1], Arch: filepath.Base(osarch)}
+		path := filepath.Join(dir, "app_"+p.OS+"_"+p.Arch)
+		if err := ioutil.WriteFile(path, []byte(osarch), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		results = append(results, BuildResult{
+			Platform:  p,
+			Package:   "example.com/app",
+			Output:    path,
+			Artifacts: []string{path},
+		})
+	}
+
+	return results
+}
+
+func TestEmitPackages_brew(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var buf bytes.Buffer
+	err = emitPackages(&buf, "brew", "https://example.com/v1/", testEmitResults(t, td))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `# example.com/app
+on_macos do
+  on_intel do
+    url "https://example.com/v1/app_darwin_amd64"
+    sha256 "` + sha256Hex("darwin/amd64") + `"
+  end
+  on_arm do
+    url "https://example.com/v1/app_darwin_arm64"
+    sha256 "` + sha256Hex("darwin/arm64") + `"
+  end
+end
+on_linux do
+  on_intel do
+    url "https://example.com/v1/app_linux_amd64"
+    sha256 "` + sha256Hex("linux/amd64") + `"
+  end
+end
+`
+	if buf.String() != expected {
+		t.Fatalf("bad:\n%s", buf.String())
+	}
+}
+
+func TestEmitPackages_scoop(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var buf bytes.Buffer
+	err = emitPackages(&buf, "scoop", "https://example.com/v1", testEmitResults(t, td))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `# example.com/app
+{
+    "architecture": {
+        "32bit": {
+            "url": "https://example.com/v1/app_windows_386",
+            "hash": "` + sha256Hex("windows/386") + `"
+        },
+        "64bit": {
+            "url": "https://example.com/v1/app_windows_amd64",
+            "hash": "` + sha256Hex("windows/amd64") + `"
+        }
+    }
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("bad:\n%s", buf.String())
+	}
+}
+
+func TestEmitPackages_unknown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := emitPackages(&buf, "apt", "https://example.com", nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func sha256Hex(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
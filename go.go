@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -11,16 +13,10 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
-	"text/template"
-)
+	"sync"
 
-type OutputTemplateData struct {
-	Dir       string
-	OS        string
-	OSUname   string
-	Arch      string
-	ArchUname string
-}
+	version "github.com/hashicorp/go-version"
+)
 
 type CompileOpts struct {
 	PackagePath string
@@ -38,13 +34,102 @@ type CompileOpts struct {
 	Buildmode   string
 	TrimPath    bool
 	GoCmd       string
+	Pgo         string
+
+	// PlatformGcflags are gcflags given for this platform only, such as
+	// "all=-N -l" to debug a single platform. They are merged with
+	// Gcflags, see gcflagsArgs.
+	PlatformGcflags string
+
+	// CgoCflags and CgoLdflags are flags for this platform only, added to
+	// CGO_CFLAGS and CGO_LDFLAGS when building with cgo. See cgoFlagsEnv.
+	CgoCflags  string
+	CgoLdflags string
+
+	// Cover builds coverage-instrumented binaries, with the mode given
+	// by CoverMode if it is set. It requires Go 1.20.
+	Cover     bool
+	CoverMode string
+
+	// BuildVCS is the value of -buildvcs (true, false or auto), if set.
+	// It is ignored for Go versions before 1.18.
+	BuildVCS string
+
+	// Race enables the race detector on platforms that support it.
+	// Other platforms are built without it.
+	Race bool
+
+	// EnvAllowlist, if non-nil, limits the inherited environment passed
+	// to go build to these variables. Variables gox sets itself, such as
+	// GOOS and GOARCH, are always passed.
+	EnvAllowlist []string
+
+	// GoCache, if set, is the GOCACHE directory for this build.
+	GoCache string
+
+	// NameSuffix is a template appended to the output file name, before
+	// any ".exe" or ".wasm" extension.
+	NameSuffix string
+
+	// GoVersion is the version of Go doing the build, such as "go1.21.4".
+	// It is only used for the output template.
+	GoVersion string
+
+	// VCS is the commit and tag of the source being built, for the
+	// output template. Either may be empty.
+	VCS VCSInfo
+
+	// Channel is the release channel given with -channel, such as
+	// "beta", for the output template. It may be empty.
+	Channel string
+
+	// OutputName, if set, replaces the file name of the rendered output
+	// template. The directory portion of the template is kept.
+	OutputName string
+
+	// GoToolchain, if set, is the GOTOOLCHAIN to build with. GoVersion
+	// must then be its version.
+	GoToolchain string
+
+	// LogFile, if set, is the file the output of go build is written to,
+	// instead of being part of the error if the build fails.
+	LogFile string
+
+	// WorkDir, if set, is a work directory created by newWorkDir. The
+	// build writes its output, temporary files and, unless GoCache is
+	// set, its cache there, and the output is then copied to its final
+	// location.
+	WorkDir string
+
+	// ctx, if set, kills the go command once it is done. The Builder
+	// sets it for cancellation and -build-timeout.
+	ctx context.Context
 }
 
-// GoCrossCompile
-func GoCrossCompile(opts *CompileOpts) error {
-	env := append(os.Environ(),
-		"GOOS="+opts.Platform.OS,
-		"GOARCH="+opts.Platform.Arch)
+// context returns the context of the build, which is never done if
+// opts.ctx isn't set.
+func (opts *CompileOpts) context() context.Context {
+	if opts.ctx == nil {
+		return context.Background()
+	}
+
+	return opts.ctx
+}
+
+// TargetEnv returns the complete environment of the go command building
+// opts for the platform: the inherited environment, limited to
+// opts.EnvAllowlist, followed by the variables gox sets, which win over
+// inherited ones. Anything that runs or shows a build must use it, so
+// that they can't disagree on the environment.
+func (p Platform) TargetEnv(opts *CompileOpts) []string {
+	return p.targetEnv(os.Environ(), opts)
+}
+
+// targetEnv is TargetEnv with the inherited environment given.
+func (p Platform) targetEnv(environ []string, opts *CompileOpts) []string {
+	env := append(filterEnv(environ, opts.EnvAllowlist),
+		"GOOS="+p.OS,
+		"GOARCH="+p.Arch)
 
 	if opts.Cc != "" {
 		env = append(env, "CC="+opts.Cc)
@@ -52,73 +137,74 @@ func GoCrossCompile(opts *CompileOpts) error {
 	if opts.Cxx != "" {
 		env = append(env, "CXX="+opts.Cxx)
 	}
-
-	// If we're building for our own platform, then enable cgo always. We
-	// respect the CGO_ENABLED flag if that is explicitly set on the platform.
-	if !opts.Cgo && os.Getenv("CGO_ENABLED") != "0" {
-		opts.Cgo = runtime.GOOS == opts.Platform.OS &&
-			runtime.GOARCH == opts.Platform.Arch
+	if opts.GoCache != "" {
+		env = append(env, "GOCACHE="+opts.GoCache)
+	} else if opts.WorkDir != "" {
+		env = append(env, "GOCACHE="+filepath.Join(opts.WorkDir, "cache"))
+	}
+	if opts.GoToolchain != "" {
+		env = append(env, "GOTOOLCHAIN="+opts.GoToolchain)
+	}
+	if opts.WorkDir != "" {
+		env = append(env, "GOTMPDIR="+filepath.Join(opts.WorkDir, "tmp"))
 	}
 
-	// If cgo is enabled then set that env var
-	if opts.Cgo {
+	// If cgo is enabled then set that env var. The race detector
+	// always needs cgo.
+	if cgoEnabled(opts.Cgo || (opts.Race && p.SupportsRace(opts.GoVersion)), p) {
 		env = append(env, "CGO_ENABLED=1")
+		env = append(env, opts.cgoFlagsEnv(env)...)
 	} else {
 		env = append(env, "CGO_ENABLED=0")
 	}
 
-	var outputPath bytes.Buffer
-	tpl, err := template.New("output").Parse(opts.OutputTpl)
+	return env
+}
+
+// GoCrossCompile
+func GoCrossCompile(opts *CompileOpts) error {
+	env := opts.Platform.TargetEnv(opts)
+
+	args, err := opts.BuildArgs()
 	if err != nil {
 		return err
 	}
-	tplData := OutputTemplateData{
-		Dir:       filepath.Base(opts.PackagePath),
-		OS:        opts.Platform.OS,
-		OSUname:   opts.Platform.OSUname(),
-		Arch:      opts.Platform.Arch,
-		ArchUname: opts.Platform.ArchUname(),
+
+	_, chdir := opts.packageDir()
+	if opts.LogFile != "" {
+		err = execGoLogged(opts.context(), opts.GoCmd, env, chdir, opts.LogFile, args...)
+	} else {
+		_, err = execGoContext(opts.context(), opts.GoCmd, env, chdir, args...)
 	}
-	if err := tpl.Execute(&outputPath, &tplData); err != nil {
+	if err != nil {
 		return err
 	}
 
-	if opts.Platform.OS == "windows" {
-		outputPath.WriteString(".exe")
+	if opts.WorkDir != "" {
+		final, err := opts.OutputPath()
+		if err != nil {
+			return err
+		}
+
+		return copyWorkOutputs(opts.workOutputPath(final), final)
 	}
 
-	// Determine the full path to the output so that we can change our
-	// working directory when executing go build.
-	outputPathReal := outputPath.String()
-	outputPathReal, err = filepath.Abs(outputPathReal)
+	return nil
+}
+
+// BuildArgs returns the arguments to the go command that build the
+// package for the platform of these options.
+func (opts *CompileOpts) BuildArgs() ([]string, error) {
+	outputPathReal, err := opts.OutputPath()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Go prefixes the import directory with '_' when it is outside
-	// the GOPATH.For this, we just drop it since we move to that
-	// directory to build.
-	chdir := ""
-	if opts.PackagePath[0] == '_' {
-		if runtime.GOOS == "windows" {
-			// We have to replace weird paths like this:
-			//
-			//   _/c_/Users
-			//
-			// With:
-			//
-			//   c:\Users
-			//
-			re := regexp.MustCompile("^/([a-zA-Z])_/")
-			chdir = re.ReplaceAllString(opts.PackagePath[1:], "$1:\\")
-			chdir = strings.Replace(chdir, "/", "\\", -1)
-		} else {
-			chdir = opts.PackagePath[1:]
-		}
-
-		opts.PackagePath = ""
+	if opts.WorkDir != "" {
+		outputPathReal = opts.workOutputPath(outputPathReal)
 	}
 
+	packagePath, _ := opts.packageDir()
+
 	args := []string{"build"}
 	if opts.Rebuild {
 		args = append(args, "-a")
@@ -128,21 +214,199 @@ func GoCrossCompile(opts *CompileOpts) error {
 	}
 	if opts.ModMode != "" {
 		args = append(args, "-mod", opts.ModMode)
-	} 
 	}
 	if opts.Buildmode != "" {
 		args = append(args, "-buildmode", opts.Buildmode)
 	}
+	if opts.Pgo != "" {
+		args = append(args, "-pgo", opts.Pgo)
+	}
+	if opts.raceEnabled() {
+		args = append(args, "-race")
+	}
+	if opts.Cover {
+		args = append(args, "-cover")
+		if opts.CoverMode != "" {
+			args = append(args, "-covermode", opts.CoverMode)
+		}
+	}
+	if opts.BuildVCS != "" {
+		// -buildvcs was added in Go 1.18, older versions reject it
+		if ok, _ := GoVersionAtLeast(opts.GoVersion, "1.18"); ok {
+			args = append(args, "-buildvcs="+opts.BuildVCS)
+		}
+	}
+	args = append(args, gcflagsArgs(opts.Gcflags, opts.PlatformGcflags)...)
 	args = append(args,
-		"-gcflags", opts.Gcflags,
 		"-ldflags", opts.Ldflags,
 		"-asmflags", opts.Asmflags,
 		"-tags", opts.Tags,
 		"-o", outputPathReal,
-		opts.PackagePath)
+		packagePath)
+
+	return args, nil
+}
+
+// gcflagsArgs returns the -gcflags arguments for the global gcflags and
+// the gcflags of a platform. Each -gcflags value applies to a single
+// package pattern, so if both use the same pattern their flags are
+// joined into one value. Otherwise both are passed and, as go build does
+// for repeated -gcflags, the platform flags win for packages matching
+// both patterns.
+func gcflagsArgs(global, platform string) []string {
+	if platform == "" {
+		return []string{"-gcflags", global}
+	}
+	if global == "" {
+		return []string{"-gcflags", platform}
+	}
+
+	globalPattern, globalFlags := splitGcflagsPattern(global)
+	platformPattern, platformFlags := splitGcflagsPattern(platform)
+	if globalPattern != platformPattern {
+		return []string{"-gcflags", global, "-gcflags", platform}
+	}
+
+	merged := globalFlags + " " + platformFlags
+	if globalPattern != "" {
+		merged = globalPattern + "=" + merged
+	}
+
+	return []string{"-gcflags", merged}
+}
+
+// splitGcflagsPattern splits a -gcflags value into its package pattern,
+// if any, and its flags, the way go build does.
+func splitGcflagsPattern(v string) (string, string) {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "-") {
+		return "", v
+	}
+
+	if idx := strings.Index(v, "="); idx >= 0 {
+		return strings.TrimSpace(v[:idx]), strings.TrimSpace(v[idx+1:])
+	}
+
+	return "", v
+}
+
+// packageDir returns the package path to pass to go build along with the
+// directory to run it in, if any.
+func (opts *CompileOpts) packageDir() (string, string) {
+	// Go prefixes the import directory with '_' when it is outside
+	// the GOPATH.For this, we just drop it since we move to that
+	// directory to build.
+	packagePath := opts.PackagePath
+	if packagePath == "" || packagePath[0] != '_' {
+		return packagePath, ""
+	}
+
+	if runtime.GOOS == "windows" {
+		// We have to replace weird paths like this:
+		//
+		//   _/c_/Users
+		//
+		// With:
+		//
+		//   c:\Users
+		//
+		re := regexp.MustCompile("^/([a-zA-Z])_/")
+		chdir := re.ReplaceAllString(packagePath[1:], "$1:\\")
+		return "", strings.Replace(chdir, "/", "\\", -1)
+	}
+
+	return "", packagePath[1:]
+}
+
+// raceEnabled reports whether this build uses the race detector.
+func (opts *CompileOpts) raceEnabled() bool {
+	return opts.Race && opts.Platform.SupportsRace(opts.GoVersion)
+}
+
+// cgoEnabled reports whether cgo will be used to build for the platform.
+// Besides when it is explicitly requested, we always enable cgo if we're
+// building for our own platform. We respect the CGO_ENABLED flag if that
+// is explicitly set on the platform.
+func cgoEnabled(cgo bool, p Platform) bool {
+	if cgo {
+		return true
+	}
+
+	return os.Getenv("CGO_ENABLED") != "0" &&
+		runtime.GOOS == p.OS && runtime.GOARCH == p.Arch
+}
+
+// cgoFlagsEnv returns the CGO_CFLAGS and CGO_LDFLAGS variables to add to
+// env for the per-platform cgo flags. The flags are appended to the
+// values in env, so they come after the inherited ones and win where the
+// compiler or linker lets later flags override earlier ones.
+func (opts *CompileOpts) cgoFlagsEnv(env []string) []string {
+	var result []string
+	for _, v := range []struct{ name, flags string }{
+		{"CGO_CFLAGS", opts.CgoCflags},
+		{"CGO_LDFLAGS", opts.CgoLdflags},
+	} {
+		if v.flags == "" {
+			continue
+		}
+
+		value := v.flags
+		if inherited := lookupEnv(env, v.name); inherited != "" {
+			value = inherited + " " + value
+		}
+		result = append(result, v.name+"="+value)
+	}
+
+	return result
+}
+
+// lookupEnv returns the value of the last entry for the variable k in
+// environ, which is the one a command sees.
+func lookupEnv(environ []string, k string) string {
+	for i := len(environ) - 1; i >= 0; i-- {
+		kv := environ[i]
+		if idx := strings.Index(kv, "="); idx >= 0 && envKey(kv[:idx]) == envKey(k) {
+			return kv[idx+1:]
+		}
+	}
+
+	return ""
+}
+
+// filterEnv returns the entries of environ whose names are in allowlist.
+// A nil allowlist keeps everything.
+func filterEnv(environ []string, allowlist []string) []string {
+	if allowlist == nil {
+		return environ
+	}
 
-	_, err = execGo(opts.GoCmd, env, chdir, args...)
-	return err
+	allowed := make(map[string]struct{}, len(allowlist))
+	for _, k := range allowlist {
+		allowed[envKey(k)] = struct{}{}
+	}
+
+	result := make([]string, 0, len(allowlist))
+	for _, kv := range environ {
+		k := kv
+		if idx := strings.Index(kv, "="); idx >= 0 {
+			k = kv[:idx]
+		}
+		if _, ok := allowed[envKey(k)]; ok {
+			result = append(result, kv)
+		}
+	}
+
+	return result
+}
+
+// envKey normalizes an environment variable name for comparison, since
+// names are case-insensitive on Windows.
+func envKey(k string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(k)
+	}
+
+	return k
 }
 
 // GoMainDirs returns the file paths to the packages that are "main"
@@ -192,6 +456,42 @@ func GoRoot() (string, error) {
 // instead of `runtime.Version()` because it is possible to run gox against
 // another Go version.
 func GoVersion() (string, error) {
+	return GoToolchainVersion("")
+}
+
+// goVersions caches the versions found by GoToolchainVersion, by
+// toolchain. Running the go command is slow, and its version doesn't
+// change during a run.
+var goVersions struct {
+	sync.Mutex
+	m map[string]string
+}
+
+// GoToolchainVersion is like GoVersion, but with GOTOOLCHAIN set to
+// toolchain, such as "go1.21.4", unless it is empty. The version is only
+// read once per toolchain in a process.
+func GoToolchainVersion(toolchain string) (string, error) {
+	goVersions.Lock()
+	defer goVersions.Unlock()
+	if v, ok := goVersions.m[toolchain]; ok {
+		return v, nil
+	}
+
+	v, err := readGoVersion(toolchain)
+	if err != nil {
+		return "", err
+	}
+
+	if goVersions.m == nil {
+		goVersions.m = make(map[string]string)
+	}
+	goVersions.m[toolchain] = v
+	return v, nil
+}
+
+// readGoVersion runs the go command to read its version, with GOTOOLCHAIN
+// set to toolchain unless it is empty.
+func readGoVersion(toolchain string) (string, error) {
 	// NOTE: We use `go run` instead of `go version` because the output
 	// of `go version` might change whereas the source is guaranteed to run
 	// for some time thanks to Go's compatibility guarantee.
@@ -209,7 +509,148 @@ func GoVersion() (string, error) {
 	}
 
 	// Execute and read the version, which will be the only thing on stdout.
-	return execGo("go", nil, "", "run", sourcePath)
+	return execGo("go", toolchainEnv(toolchain), "", "run", sourcePath)
+}
+
+// toolchainEnv returns the environment to run the go command with to use
+// toolchain, or nil to inherit the environment if toolchain is empty.
+func toolchainEnv(toolchain string) []string {
+	if toolchain == "" {
+		return nil
+	}
+
+	return append(os.Environ(), "GOTOOLCHAIN="+toolchain)
+}
+
+// DistListPlatforms returns the platforms reported by `go tool dist list`
+// for the `go` binary on the PATH. Platforms that are a default in
+// PlatformsLatest remain a default; everything else is not. The returned
+// slice is newly allocated and safe to modify.
+func DistListPlatforms() ([]Platform, error) {
+	return distListPlatforms("")
+}
+
+// distLists caches the output of `go tool dist list` by toolchain, like
+// goVersions.
+var distLists struct {
+	sync.Mutex
+	m map[string]string
+}
+
+// distListPlatforms is DistListPlatforms with GOTOOLCHAIN set to
+// toolchain, unless it is empty. The go command only runs once per
+// toolchain in a process.
+func distListPlatforms(toolchain string) ([]Platform, error) {
+	distLists.Lock()
+	defer distLists.Unlock()
+
+	output, ok := distLists.m[toolchain]
+	if !ok {
+		var err error
+		output, err = execGo("go", toolchainEnv(toolchain), "", "tool", "dist", "list")
+		if err != nil {
+			return nil, err
+		}
+
+		if distLists.m == nil {
+			distLists.m = make(map[string]string)
+		}
+		distLists.m[toolchain] = output
+	}
+
+	return parseDistList(output)
+}
+
+func parseDistList(output string) ([]Platform, error) {
+	defaults := make(map[string]bool)
+	for _, p := range PlatformsLatest {
+		if p.Default {
+			defaults[p.String()] = true
+		}
+	}
+
+	var result []Platform
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected dist list line: %s", line)
+		}
+
+		p := Platform{OS: parts[0], Arch: parts[1]}
+		p.Default = defaults[p.String()]
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+// DistPlatform is a platform as reported by `go tool dist list -json`.
+type DistPlatform struct {
+	GOOS         string
+	GOARCH       string
+	CgoSupported bool
+	FirstClass   bool
+}
+
+// DistListJSON returns the platforms reported by `go tool dist list -json`
+// for the `go` binary on the PATH. This requires Go 1.13 or later.
+func DistListJSON() ([]DistPlatform, error) {
+	output, err := execGo("go", nil, "", "tool", "dist", "list", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []DistPlatform
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("error parsing dist list: %s", err)
+	}
+
+	return result, nil
+}
+
+// distPorts caches the ports reported by DistListJSON, since they don't
+// change during a run. Both maps are nil if it failed.
+var distPorts struct {
+	sync.Once
+	all        map[string]bool
+	firstClass map[string]bool
+}
+
+func loadDistPorts() {
+	distPorts.Do(func() {
+		platforms, err := DistListJSON()
+		if err != nil {
+			return
+		}
+
+		distPorts.all = make(map[string]bool)
+		distPorts.firstClass = make(map[string]bool)
+		for _, p := range platforms {
+			distPorts.all[p.GOOS+"/"+p.GOARCH] = true
+			if p.FirstClass {
+				distPorts.firstClass[p.GOOS+"/"+p.GOARCH] = true
+			}
+		}
+	})
+}
+
+// distFirstClassPorts returns the set of first class ports reported by
+// DistListJSON, or nil if it failed.
+func distFirstClassPorts() map[string]bool {
+	loadDistPorts()
+	return distPorts.firstClass
+}
+
+// distAllPorts returns the set of every port reported by DistListJSON,
+// or nil if it failed.
+func distAllPorts() map[string]bool {
+	loadDistPorts()
+	return distPorts.all
 }
 
 // GoVersionParts parses the version numbers from the version itself
@@ -224,9 +665,37 @@ func GoVersionParts() (result [2]int, err error) {
 	return
 }
 
+// GoVersionAtLeast reports whether the Go version v, as returned by
+// GoVersion, is at least min. Versions without a "go" prefix, such as
+// development builds, are assumed to be new enough.
+func GoVersionAtLeast(v, min string) (bool, error) {
+	if !strings.HasPrefix(v, "go") {
+		return true, nil
+	}
+
+	// go-version only cares about version numbers
+	current, err := version.NewVersion(v[2:])
+	if err != nil {
+		return false, fmt.Errorf(
+			"Unable to parse current go version: %s\n%s", v, err.Error())
+	}
+
+	constraint, err := version.NewConstraint(">= " + min)
+	if err != nil {
+		panic(err)
+	}
+
+	return constraint.Check(current), nil
+}
+
 func execGo(GoCmd string, env []string, dir string, args ...string) (string, error) {
+	return execGoContext(context.Background(), GoCmd, env, dir, args...)
+}
+
+// execGoContext is execGo, killing the go command once ctx is done.
+func execGoContext(ctx context.Context, GoCmd string, env []string, dir string, args ...string) (string, error) {
 	var stderr, stdout bytes.Buffer
-	cmd := exec.Command(GoCmd, args...)
+	cmd := exec.CommandContext(ctx, GoCmd, args...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	if env != nil {
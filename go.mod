@@ -1,6 +1,14 @@
 module github.com/mitchellh/gox
 
+go 1.17
+
 require (
+	github.com/fsnotify/fsnotify v1.5.4
 	github.com/hashicorp/go-version v1.0.0
+	github.com/klauspost/compress v1.15.15
 	github.com/mitchellh/iochan v1.0.0
+	github.com/ulikunitz/xz v0.5.11
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.0.0-20220412211240-33da011f77ad // indirect
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -35,3 +37,258 @@ func TestGoVersion(t *testing.T) {
 		t.Fatalf("bad: %#v", v)
 	}
 }
+
+func TestGoToolchainVersion_cached(t *testing.T) {
+	goVersions.Lock()
+	if goVersions.m == nil {
+		goVersions.m = make(map[string]string)
+	}
+	goVersions.m["gox-test"] = "go1.2.3"
+	goVersions.Unlock()
+	defer func() {
+		goVersions.Lock()
+		delete(goVersions.m, "gox-test")
+		goVersions.Unlock()
+	}()
+
+	// A real lookup of this toolchain would fail
+	v, err := GoToolchainVersion("gox-test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "go1.2.3" {
+		t.Fatalf("bad: %s", v)
+	}
+}
+
+func TestParseDistList(t *testing.T) {
+	ps, err := parseDistList("linux/amd64\nwasip1/wasm\n\n")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Platform{
+		{"linux", "amd64", true},
+		{"wasip1", "wasm", false},
+	}
+	if !reflect.DeepEqual(ps, expected) {
+		t.Fatalf("bad: %#v", ps)
+	}
+
+	if _, err := parseDistList("linux\n"); err == nil {
+		t.Fatal("should err")
+	}
+}
+
+func TestFilterEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/root", "SECRET=x", "EMPTY="}
+
+	if actual := filterEnv(environ, nil); !reflect.DeepEqual(actual, environ) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	actual := filterEnv(environ, []string{"PATH", "EMPTY", "MISSING"})
+	expected := []string{"PATH=/bin", "EMPTY="}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if actual := filterEnv(environ, []string{}); len(actual) != 0 {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestPlatformTargetEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "GOOS=plan9", "CGO_LDFLAGS=-L/opt/lib", "SECRET=x"}
+	p := Platform{OS: "linux", Arch: "riscv64"}
+
+	opts := &CompileOpts{
+		Platform:     p,
+		Cc:           "riscv64-linux-gnu-gcc",
+		Cgo:          true,
+		CgoLdflags:   "-lrt",
+		EnvAllowlist: []string{"PATH", "GOOS", "CGO_LDFLAGS"},
+		GoToolchain:  "go1.22.0",
+		WorkDir:      "/work",
+	}
+	expected := []string{
+		"PATH=/bin",
+		"GOOS=plan9",
+		"CGO_LDFLAGS=-L/opt/lib",
+		"GOOS=linux",
+		"GOARCH=riscv64",
+		"CC=riscv64-linux-gnu-gcc",
+		"GOCACHE=" + filepath.Join("/work", "cache"),
+		"GOTOOLCHAIN=go1.22.0",
+		"GOTMPDIR=" + filepath.Join("/work", "tmp"),
+		"CGO_ENABLED=1",
+		"CGO_LDFLAGS=-L/opt/lib -lrt",
+	}
+	if actual := p.targetEnv(environ, opts); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Without cgo, the cgo flags are left out
+	opts = &CompileOpts{Platform: p, CgoLdflags: "-lrt", EnvAllowlist: []string{}}
+	expected = []string{"GOOS=linux", "GOARCH=riscv64", "CGO_ENABLED=0"}
+	if actual := p.targetEnv(environ, opts); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestCompileOptsCgoFlagsEnv(t *testing.T) {
+	var cflags, ldflags PlatformOverrideFlag
+	if err := cflags.Set("linux/*=-O3"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ldflags.Set("linux/arm*=-lrt"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	env := []string{"CGO_LDFLAGS=-L/opt/lib", "CGO_LDFLAGS=-L/usr/local/lib"}
+	cases := []struct {
+		Platform Platform
+		Expected []string
+	}{
+		{
+			Platform{OS: "linux", Arch: "arm64"},
+			[]string{"CGO_CFLAGS=-O3", "CGO_LDFLAGS=-L/usr/local/lib -lrt"},
+		},
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			[]string{"CGO_CFLAGS=-O3"},
+		},
+		{
+			Platform{OS: "darwin", Arch: "arm64"},
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &CompileOpts{Platform: tc.Platform}
+		opts.CgoCflags, _ = cflags.Lookup(tc.Platform)
+		opts.CgoLdflags, _ = ldflags.Lookup(tc.Platform)
+
+		if actual := opts.cgoFlagsEnv(env); !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%s: bad: %#v", tc.Platform.String(), actual)
+		}
+	}
+}
+
+func TestCompileOptsBuildArgs_buildVCS(t *testing.T) {
+	cases := []struct {
+		GoVersion string
+		Expected  bool
+	}{
+		{"go1.17.13", false},
+		{"go1.18", true},
+		{"go1.21.4", true},
+		{"devel +abcd", true},
+	}
+
+	for _, tc := range cases {
+		opts := &CompileOpts{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "linux", Arch: "amd64"},
+			OutputTpl:   "{{.Dir}}",
+			BuildVCS:    "true",
+			GoVersion:   tc.GoVersion,
+		}
+
+		args, err := opts.BuildArgs()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		found := false
+		for _, arg := range args {
+			if arg == "-buildvcs=true" {
+				found = true
+			}
+		}
+		if found != tc.Expected {
+			t.Fatalf("%s: bad: %#v", tc.GoVersion, args)
+		}
+	}
+}
+
+func TestGcflagsArgs(t *testing.T) {
+	cases := []struct {
+		Global, Platform string
+		Expected         []string
+	}{
+		{"", "", []string{"-gcflags", ""}},
+		{"-m", "", []string{"-gcflags", "-m"}},
+		{"", "all=-N -l", []string{"-gcflags", "all=-N -l"}},
+		{"-m", "-N -l", []string{"-gcflags", "-m -N -l"}},
+		{"all=-m", "all=-N -l", []string{"-gcflags", "all=-m -N -l"}},
+		{"-m", "all=-N -l", []string{"-gcflags", "-m", "-gcflags", "all=-N -l"}},
+	}
+
+	for _, tc := range cases {
+		actual := gcflagsArgs(tc.Global, tc.Platform)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%q, %q: bad: %#v", tc.Global, tc.Platform, actual)
+		}
+	}
+}
+
+func TestCompileOptsBuildArgs_gcflagsOverride(t *testing.T) {
+	var override PlatformOverrideFlag
+	if err := override.Set("linux/arm*=all=-N -l"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string][]string{
+		"linux/arm64":   {"-gcflags", "-m", "-gcflags", "all=-N -l"},
+		"linux/arm":     {"-gcflags", "-m", "-gcflags", "all=-N -l"},
+		"linux/amd64":   {"-gcflags", "-m"},
+		"windows/arm64": {"-gcflags", "-m"},
+	}
+
+	for osarch, expected := range cases {
+		parts := strings.Split(osarch, "/")
+		opts := &CompileOpts{
+			PackagePath: "foo",
+			Platform:    Platform{OS: parts[0], Arch: parts[1]},
+			OutputTpl:   "{{.Dir}}",
+			Gcflags:     "-m",
+		}
+		opts.PlatformGcflags, _ = override.Lookup(opts.Platform)
+
+		args, err := opts.BuildArgs()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var gcflags []string
+		for i, arg := range args {
+			if arg == "-gcflags" {
+				gcflags = append(gcflags, arg, args[i+1])
+			}
+		}
+		if !reflect.DeepEqual(gcflags, expected) {
+			t.Fatalf("%s: bad: %#v", osarch, gcflags)
+		}
+	}
+}
+
+func TestCompileOptsBuildArgs_cover(t *testing.T) {
+	opts := &CompileOpts{
+		PackagePath: "foo",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		OutputTpl:   "{{.Dir}}",
+		Cover:       true,
+		CoverMode:   "atomic",
+	}
+
+	args, err := opts.BuildArgs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"build", "-cover", "-covermode", "atomic", "-gcflags"}
+	if !reflect.DeepEqual(args[:len(expected)], expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
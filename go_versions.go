@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+)
+
+// goTarget is a version of Go to build with, along with the platforms to
+// build for with it.
+type goTarget struct {
+	// Toolchain is the GOTOOLCHAIN to build with, or empty for the go
+	// command on the PATH.
+	Toolchain string
+	GoVersion string
+	Platforms []Platform
+}
+
+// parseGoVersions splits the value of -go-versions, a list of GOTOOLCHAIN
+// values separated by commas or spaces.
+func parseGoVersions(s string) []string {
+	return strings.Fields(strings.Replace(s, ",", " ", -1))
+}
+
+// oldestGoVersion returns the oldest Go version of the targets, which
+// decides the options that can be used for all of them. Versions that
+// can't be parsed, such as development versions, are assumed to be new.
+func oldestGoVersion(targets []goTarget) string {
+	var oldest string
+	var oldestVersion *version.Version
+	for _, t := range targets {
+		v, err := version.NewVersion(strings.TrimPrefix(t.GoVersion, "go"))
+		if err != nil {
+			if oldest == "" {
+				oldest = t.GoVersion
+			}
+			continue
+		}
+
+		if oldestVersion == nil || v.LessThan(oldestVersion) {
+			oldest, oldestVersion = t.GoVersion, v
+		}
+	}
+
+	return oldest
+}
+
+// targetPlatforms returns every platform of the targets once, in the
+// order they are first found.
+func targetPlatforms(targets []goTarget) []Platform {
+	seen := make(map[string]bool)
+	var result []Platform
+	for _, t := range targets {
+		for _, p := range t.Platforms {
+			if !seen[p.String()] {
+				seen[p.String()] = true
+				result = append(result, p)
+			}
+		}
+	}
+
+	return result
+}
+
+// outputUsesGoVersion reports whether the output template or the name
+// suffix of opts reference the Go version, which keeps the outputs of
+// different Go versions apart.
+func outputUsesGoVersion(opts *CompileOpts) bool {
+	fields := make(map[string]bool)
+	for i, text := range []string{opts.OutputTpl, opts.NameSuffix} {
+		if err := templateFields(fmt.Sprintf("t%d", i), text, fields); err != nil {
+			return false
+		}
+	}
+
+	return fields["GoVersion"]
+}
+
+// sortGoVersions sorts Go versions such as "go1.9" and "go1.10" from
+// oldest to newest. Versions that can't be parsed, such as development
+// versions, are assumed to be new and sort last, by name.
+func sortGoVersions(versions []string) {
+	parsed := make(map[string]*version.Version, len(versions))
+	for _, v := range versions {
+		if pv, err := version.NewVersion(strings.TrimPrefix(v, "go")); err == nil {
+			parsed[v] = pv
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		vi, vj := parsed[versions[i]], parsed[versions[j]]
+		switch {
+		case vi != nil && vj != nil:
+			return vi.LessThan(vj)
+		case vi != nil || vj != nil:
+			return vi != nil
+		default:
+			return versions[i] < versions[j]
+		}
+	})
+}
+
+// printGoVersionReport writes how many builds succeeded, failed and were
+// skipped with each Go version. Builds reused with -resume succeeded.
+func printGoVersionReport(w io.Writer, results []BuildResult) {
+	succeeded := make(map[string]int)
+	failed := make(map[string]int)
+	skipped := make(map[string]int)
+	seen := make(map[string]bool)
+	var versions []string
+	for _, r := range results {
+		if !seen[r.GoVersion] {
+			seen[r.GoVersion] = true
+			versions = append(versions, r.GoVersion)
+		}
+		switch r.status() {
+		case StatusSuccess, StatusCached:
+			succeeded[r.GoVersion]++
+		case StatusSkipped:
+			skipped[r.GoVersion]++
+		default:
+			failed[r.GoVersion]++
+		}
+	}
+	sortGoVersions(versions)
+
+	fmt.Fprintf(w, "\nBuilds by Go version:\n")
+	for _, v := range versions {
+		fmt.Fprintf(w, "  %s: %d succeeded, %d failed", v, succeeded[v], failed[v])
+		if skipped[v] > 0 {
+			fmt.Fprintf(w, ", %d skipped", skipped[v])
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseGoVersions(t *testing.T) {
+	actual := parseGoVersions("go1.20.5, go1.21.4 go1.22.0,")
+	expected := []string{"go1.20.5", "go1.21.4", "go1.22.0"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestOldestGoVersion(t *testing.T) {
+	cases := []struct {
+		Versions []string
+		Expected string
+	}{
+		{[]string{"go1.21.4"}, "go1.21.4"},
+		{[]string{"go1.21.4", "go1.20.5", "go1.22.0"}, "go1.20.5"},
+		{[]string{"go1.9", "go1.10"}, "go1.9"},
+		{[]string{"devel +abcd", "go1.21.4"}, "go1.21.4"},
+		{[]string{"devel +abcd"}, "devel +abcd"},
+	}
+
+	for _, tc := range cases {
+		var targets []goTarget
+		for _, v := range tc.Versions {
+			targets = append(targets, goTarget{GoVersion: v})
+		}
+
+		if actual := oldestGoVersion(targets); actual != tc.Expected {
+			t.Fatalf("%v: bad: %s", tc.Versions, actual)
+		}
+	}
+}
+
+func TestTargetPlatforms(t *testing.T) {
+	linux := Platform{OS: "linux", Arch: "amd64"}
+	wasip1 := Platform{OS: "wasip1", Arch: "wasm"}
+	targets := []goTarget{
+		{GoVersion: "go1.20.5", Platforms: []Platform{linux}},
+		{GoVersion: "go1.21.4", Platforms: []Platform{linux, wasip1}},
+	}
+
+	actual := targetPlatforms(targets)
+	if !reflect.DeepEqual(actual, []Platform{linux, wasip1}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestOutputUsesGoVersion(t *testing.T) {
+	cases := []struct {
+		Opts     CompileOpts
+		Expected bool
+	}{
+		{CompileOpts{OutputTpl: "{{.Dir}}_{{.OS}}_{{.Arch}}"}, false},
+		{CompileOpts{OutputTpl: "dist/{{.GoVersion}}/{{.Dir}}"}, true},
+		{CompileOpts{OutputTpl: "{{.Dir}}", NameSuffix: "-{{.GoVersion}}"}, true},
+	}
+
+	for _, tc := range cases {
+		if actual := outputUsesGoVersion(&tc.Opts); actual != tc.Expected {
+			t.Fatalf("%#v: bad: %v", tc.Opts, actual)
+		}
+	}
+}
+
+func TestPrintGoVersionReport(t *testing.T) {
+	results := []BuildResult{
+		{GoVersion: "go1.21.4"},
+		{GoVersion: "go1.20.5", Err: errors.New("failed")},
+		{GoVersion: "go1.21.4", Err: errors.New("failed")},
+		{GoVersion: "go1.20.5"},
+		{GoVersion: "go1.20.5", Status: StatusCached},
+		{GoVersion: "go1.21.4", Status: StatusSkipped, Err: errors.New("skipped")},
+	}
+
+	var buf bytes.Buffer
+	printGoVersionReport(&buf, results)
+	expected := "\nBuilds by Go version:\n" +
+		"  go1.20.5: 2 succeeded, 1 failed\n" +
+		"  go1.21.4: 1 succeeded, 1 failed, 1 skipped\n"
+	if buf.String() != expected {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
+
+func TestSortGoVersions(t *testing.T) {
+	versions := []string{"devel +abc", "go1.10", "go1.9.7", "go1.21.4", "go1.9"}
+	sortGoVersions(versions)
+
+	expected := []string{"go1.9", "go1.9.7", "go1.10", "go1.21.4", "devel +abc"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Fatalf("bad: %#v", versions)
+	}
+}
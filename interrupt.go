@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// cancelOnInterrupt returns a channel that is closed once gox is
+// interrupted or terminated, for Builder.Cancel, and a function that
+// stops listening for the signals. A second signal exits right away,
+// after calling cleanup.
+func cancelOnInterrupt(cleanup func()) (<-chan struct{}, func()) {
+	signals := make(chan os.Signal, 1)
+	cancel := make(chan struct{})
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "\nReceived %s, cancelling the builds\n", sig)
+			close(cancel)
+		case <-done:
+			return
+		}
+
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "\nReceived %s again, exiting\n", sig)
+			cleanup()
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+
+	return cancel, func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
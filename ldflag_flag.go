@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LdflagFlag is a flag.Value that collects linker flags one at a time,
+// for example -ldflag '-X main.version=1.2.3'. Each occurrence is a flag,
+// optionally followed by a space and its value, which may contain spaces
+// itself. This avoids quoting the whole -ldflags string for the shell.
+type LdflagFlag struct {
+	args []string
+}
+
+// Set adds a single linker flag, quoting its value for go build.
+func (f *LdflagFlag) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return fmt.Errorf("empty linker flag")
+	}
+
+	fields := []string{s}
+	if idx := strings.Index(s, " "); idx > 0 {
+		fields = []string{s[:idx], strings.TrimSpace(s[idx+1:])}
+	}
+
+	for i, field := range fields {
+		quoted, err := quoteLdflag(field)
+		if err != nil {
+			return err
+		}
+
+		fields[i] = quoted
+	}
+
+	f.args = append(f.args, strings.Join(fields, " "))
+	return nil
+}
+
+func (f *LdflagFlag) String() string {
+	return strings.Join(f.args, " ")
+}
+
+// Join appends the collected linker flags to ldflags, the value of the
+// -ldflags flag.
+func (f *LdflagFlag) Join(ldflags string) string {
+	if len(f.args) == 0 {
+		return ldflags
+	}
+	if ldflags == "" {
+		return f.String()
+	}
+
+	return ldflags + " " + f.String()
+}
+
+// quoteLdflag quotes s so that go build parses it as a single argument.
+// Go splits flag lists at spaces and accepts single or double quotes
+// around an argument, but has no way to escape a quote.
+func quoteLdflag(s string) (string, error) {
+	if !strings.ContainsAny(s, " \t\n\r'\"") {
+		return s, nil
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'", nil
+	}
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`, nil
+	}
+
+	return "", fmt.Errorf(
+		"linker flag %q can't contain both single and double quotes", s)
+}
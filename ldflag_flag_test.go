@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLdflagFlag(t *testing.T) {
+	var f LdflagFlag
+	for _, s := range []string{
+		"-s",
+		"-w",
+		"-X main.version=1.2.3",
+		"-X main.name=hello world",
+		"-X main.quote=it's",
+	} {
+		if err := f.Set(s); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	expected := `-s -w -X main.version=1.2.3 -X 'main.name=hello world' -X "main.quote=it's"`
+	if actual := f.String(); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	if actual := f.Join("-linkmode external"); actual != "-linkmode external "+expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestLdflagFlag_invalid(t *testing.T) {
+	var f LdflagFlag
+	for _, s := range []string{"", "  ", `-X main.v='"`} {
+		if err := f.Set(s); err == nil {
+			t.Fatalf("%q: should error", s)
+		}
+	}
+}
+
+func TestLdflagFlagJoin_empty(t *testing.T) {
+	var f LdflagFlag
+	if actual := f.Join("-s"); actual != "-s" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	f.Set("-w")
+	if actual := f.Join(""); actual != "-w" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
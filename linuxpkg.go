@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PackageOpts configures the packages made by -package.
+type PackageOpts struct {
+	// Formats are the package formats to make, "deb", "rpm" and "tgz".
+	Formats []string
+
+	Version     string
+	Maintainer  string
+	Description string
+
+	// Compression is the compression of tgz tarballs, a key of
+	// tarballCompressors.
+	Compression string
+
+	// Mode, if set, is the mode of the binary in every package, 0755
+	// otherwise.
+	Mode os.FileMode
+}
+
+// linuxPackage is a package that installs a single binary to /usr/bin.
+// Tarballs are made from it too, for every platform.
+type linuxPackage struct {
+	Name        string
+	Version     string
+	Maintainer  string
+	Description string
+	Compression string
+	Mode        os.FileMode
+
+	// Platform is the platform the binary is built for.
+	Platform Platform
+
+	// Binary is the path of the binary to package.
+	Binary string
+
+	// ModTime is the time of every file in the package, for packages
+	// to be reproducible.
+	ModTime time.Time
+}
+
+// binaryMode returns the mode of the binary in the package, p.Mode if it
+// is set or else 0755.
+func (p *linuxPackage) binaryMode() os.FileMode {
+	if p.Mode == 0 {
+		return 0755
+	}
+
+	return p.Mode
+}
+
+// packageWriters write a linuxPackage in each format of -package, and
+// packageFileNames name the files they are written to.
+var (
+	packageWriters = map[string]func(path string, p linuxPackage) error{
+		"deb": writeDeb,
+		"rpm": writeRPM,
+		"tgz": writeTarball,
+	}
+	packageFileNames = map[string]func(p linuxPackage) string{
+		"deb": func(p linuxPackage) string {
+			return fmt.Sprintf("%s_%s_%s.deb", p.Name, p.Version, p.Platform.DebianArch())
+		},
+		"rpm": func(p linuxPackage) string {
+			return fmt.Sprintf("%s-%s-1.%s.rpm", p.Name, p.Version, p.Platform.RPMArch())
+		},
+		"tgz": tarballFileName,
+	}
+)
+
+// packageArches return the architecture name of a platform for each
+// Linux package format. Formats not in it, such as tgz, are made for
+// every platform.
+var packageArches = map[string]func(p *Platform) string{
+	"deb": (*Platform).DebianArch,
+	"rpm": (*Platform).RPMArch,
+}
+
+// packageArchives are the formats that only hold the binary, so that they
+// replace it as an artifact instead of being shipped along with it.
+var packageArchives = map[string]bool{
+	"tgz": true,
+}
+
+// packageStep returns a post-build step that packages binaries in each of
+// the formats, next to the binary. The packages are added to the
+// artifacts of the build, and archives replace the binary there. Only
+// linux binaries get Linux packages.
+func packageStep(pkg PackageOpts) postBuildStep {
+	return func(opts *CompileOpts, result *BuildResult) error {
+		modTime, err := packageModTime(result.Output)
+		if err != nil {
+			return err
+		}
+
+		p := linuxPackage{
+			Name:        linuxPackageName(packageName(opts.PackagePath)),
+			Version:     pkg.Version,
+			Maintainer:  pkg.Maintainer,
+			Description: pkg.Description,
+			Compression: pkg.Compression,
+			Mode:        pkg.Mode,
+			Platform:    opts.Platform,
+			Binary:      result.Output,
+			ModTime:     modTime,
+		}
+		if p.Description == "" {
+			p.Description = p.Name
+		}
+
+		for _, format := range pkg.Formats {
+			if arch, ok := packageArches[format]; ok {
+				if opts.Platform.OS != "linux" {
+					continue
+				}
+				if arch(&p.Platform) == "" {
+					return fmt.Errorf("no %s architecture for %s", format, opts.Platform.String())
+				}
+			}
+
+			path := filepath.Join(filepath.Dir(result.Output), packageFileNames[format](p))
+			if err := packageWriters[format](path, p); err != nil {
+				return fmt.Errorf("making %s package: %s", format, err)
+			}
+
+			if packageArchives[format] {
+				result.Artifacts = replaceArtifact(result.Artifacts, result.Output, path)
+			} else {
+				result.Artifacts = append(result.Artifacts, path)
+			}
+		}
+
+		return nil
+	}
+}
+
+// replaceArtifact replaces old with new in artifacts, keeping its place,
+// or appends new if old isn't one of them.
+func replaceArtifact(artifacts []string, old, new string) []string {
+	for i, a := range artifacts {
+		if a == old {
+			artifacts[i] = new
+			return artifacts
+		}
+	}
+
+	return append(artifacts, new)
+}
+
+// parsePackageFormats splits the value of -package, a list of formats
+// separated by commas or spaces.
+func parsePackageFormats(s string) ([]string, error) {
+	formats := strings.Fields(strings.Replace(s, ",", " ", -1))
+	for _, f := range formats {
+		if packageWriters[f] == nil {
+			return nil, fmt.Errorf("unknown package format %q, it should be deb, rpm or tgz", f)
+		}
+	}
+
+	return formats, nil
+}
+
+// linuxPackageName turns the name of a binary into a package name, which
+// may only contain lowercase letters, digits and "+-.".
+func linuxPackageName(name string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '+', c == '-', c == '.':
+			return c
+		case c >= 'A' && c <= 'Z':
+			return c - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, name)
+}
+
+// packageVersion turns a version such as a git tag into one that both
+// dpkg and rpm accept: "v1.2.0-rc.1" becomes "1.2.0~rc.1", which sorts
+// before "1.2.0" like the pre-release it is.
+func packageVersion(v string) (string, error) {
+	v = strings.Replace(strings.TrimPrefix(v, "v"), "-", "~", -1)
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		return "", fmt.Errorf("package version %q must start with a digit", v)
+	}
+	for _, c := range v {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			c == '.' || c == '+' || c == '~') {
+			return "", fmt.Errorf("package version %q can't contain %q", v, c)
+		}
+	}
+
+	return v, nil
+}
+
+// packageModTime returns the time to stamp the files of a package with:
+// SOURCE_DATE_EPOCH if it is set, or else the modification time of the
+// binary.
+func packageModTime(binary string) (time.Time, error) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", epoch)
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+
+	info, err := os.Stat(binary)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return info.ModTime().UTC().Truncate(time.Second), nil
+}
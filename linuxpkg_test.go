@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePackageFormats(t *testing.T) {
+	formats, err := parsePackageFormats("deb, rpm")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(formats, []string{"deb", "rpm"}) {
+		t.Fatalf("bad: %#v", formats)
+	}
+
+	if _, err := parsePackageFormats("deb apk"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestPackageVersion(t *testing.T) {
+	cases := map[string]string{
+		"1.2.0":        "1.2.0",
+		"v1.2.0":       "1.2.0",
+		"v1.2.0-rc.1":  "1.2.0~rc.1",
+		"2.0.0+build5": "2.0.0+build5",
+	}
+	for input, expected := range cases {
+		actual, err := packageVersion(input)
+		if err != nil {
+			t.Fatalf("%s: err: %s", input, err)
+		}
+		if actual != expected {
+			t.Fatalf("%s: bad: %s", input, actual)
+		}
+	}
+
+	for _, input := range []string{"", "latest", "1.0 beta", "1.0_1"} {
+		if _, err := packageVersion(input); err == nil {
+			t.Fatalf("%s: should error", input)
+		}
+	}
+}
+
+func TestLinuxPackageName(t *testing.T) {
+	if actual := linuxPackageName("My_App"); actual != "my-app" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestPackageModTime(t *testing.T) {
+	defer os.Setenv("SOURCE_DATE_EPOCH", os.Getenv("SOURCE_DATE_EPOCH"))
+
+	os.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	actual, err := packageModTime("does-not-exist")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !actual.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	os.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := packageModTime("does-not-exist"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestPackageStep(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	step := packageStep(PackageOpts{
+		Formats:     []string{"deb", "tgz"},
+		Version:     "1.2.0",
+		Compression: "gzip",
+	})
+
+	// The tarball takes the place of the binary, the deb is shipped too,
+	// and windows only gets the tarball
+	cases := []struct {
+		Platform Platform
+		Binary   string
+		Expected []string
+	}{
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"app_linux_amd64",
+			[]string{"app_linux_amd64.tar.gz", "app_1.2.0_amd64.deb"},
+		},
+		{
+			Platform{OS: "windows", Arch: "amd64"},
+			"app_windows_amd64.exe",
+			[]string{"app_windows_amd64.tar.gz"},
+		},
+	}
+	for _, tc := range cases {
+		binary := filepath.Join(td, tc.Binary)
+		if err := ioutil.WriteFile(binary, []byte("binary"), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		opts := &CompileOpts{PackagePath: "example.com/app", Platform: tc.Platform}
+		result := &BuildResult{Output: binary, Artifacts: []string{binary}}
+		if err := step(opts, result); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var expected []string
+		for _, name := range tc.Expected {
+			expected = append(expected, filepath.Join(td, name))
+		}
+		if !reflect.DeepEqual(result.Artifacts, expected) {
+			t.Fatalf("%s: bad: %#v", tc.Platform.String(), result.Artifacts)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// logFileName returns the name of the build log of a package for a
+// platform in the -log-dir. The package is only part of the name when
+// more than one package is built, and the Go version if it isn't empty.
+// The whole import path of the package is used, with "/" and other
+// characters that don't belong in a file name replaced by "_", so that
+// packages of the same name in different directories get their own log.
+func logFileName(p Platform, pkg string, multiplePackages bool, goVersion string) string {
+	name := p.OS + "_" + p.Arch
+	if multiplePackages {
+		name += "_" + strings.Map(func(c rune) rune {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+				c == '.', c == '-', c == '_':
+				return c
+			default:
+				return '_'
+			}
+		}, strings.TrimPrefix(pkg, "./"))
+	}
+	if goVersion != "" {
+		name += "_" + goVersion
+	}
+
+	return name + ".log"
+}
+
+// execGoLogged is like execGoContext, except that the command line and
+// all of the output of the command are written to the file at logPath
+// instead of being returned. A failure refers to the log.
+func execGoLogged(ctx context.Context, goCmd string, env []string, dir string, logPath string, args ...string) error {
+	f, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "$ %s %s\n", goCmd, strings.Join(args, " "))
+
+	cmd := exec.CommandContext(ctx, goCmd, args...)
+	cmd.Stdout = f
+	cmd.Stderr = f
+	if env != nil {
+		cmd.Env = env
+	}
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s, see %s", err, logPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFileName(t *testing.T) {
+	p := Platform{OS: "linux", Arch: "arm64"}
+	if actual := logFileName(p, "example.com/foo/cmd/bar", false, ""); actual != "linux_arm64.log" {
+		t.Fatalf("bad: %s", actual)
+	}
+	if actual := logFileName(p, "example.com/foo/cmd/bar", true, ""); actual != "linux_arm64_example.com_foo_cmd_bar.log" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// Packages of the same name get their own logs
+	a := logFileName(p, "example.com/foo/cmd/a/tool", true, "")
+	b := logFileName(p, "example.com/foo/cmd/b/tool", true, "")
+	if a == b {
+		t.Fatalf("bad: both are %s", a)
+	}
+	if actual := logFileName(p, "example.com/foo/cmd/bar", false, "go1.21.4"); actual != "linux_arm64_go1.21.4.log" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestExecGoLogged(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	logPath := filepath.Join(td, "linux_amd64.log")
+	if err := execGoLogged(context.Background(), "go", nil, "", logPath, "version"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasPrefix(string(data), "$ go version\ngo version go") {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// A failure points at the log
+	err = execGoLogged(context.Background(), "go", nil, "", logPath, "no-such-command")
+	if err == nil || !strings.Contains(err.Error(), logPath) {
+		t.Fatalf("bad: %v", err)
+	}
+}
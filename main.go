@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
-	"sync"
+	"time"
 
 	version "github.com/hashicorp/go-version"
 )
@@ -15,28 +16,81 @@ import (
 func main() {
 	// Call realMain so that defers work properly, since os.Exit won't
 	// call defers.
-	os.Exit(realMain())
+	os.Exit(realMain(os.Args[1:], nil))
 }
 
-func realMain() int {
+// realMain runs gox with the command-line arguments args. If detected is
+// not nil, its Go version and platforms are used instead of detecting
+// them, and the flags that select platforms, such as -osarch, -only,
+// -go-versions, -platforms-for and -recent-platforms, are ignored.
+func realMain(args []string, detected *Detected) int {
 	var buildToolchain bool
 	var ldflags string
+	var ldflagFlag LdflagFlag
 	var outputTpl string
 	var parallel int
 	var platformFlag PlatformFlag
+	var flagOnly string
+	var flagGoVersions string
 	var tags string
 	var verbose bool
 	var flagGcflags, flagAsmflags, flagBuildmode string
 	var flagCgo, flagRebuild, flagTrimPath, flagListOSArch bool
+	var flagDumpPlatforms bool
 	var flagGoCmd string
 	var modMode string
+	var flagListArtifacts, flagAttestSubjects string
+	var flagChecksumFormat string
+	var outputOverride PlatformOverrideFlag
+	var gcflagsOverride PlatformOverrideFlag
+	var cgoCflagsOverride, cgoLdflagsOverride PlatformOverrideFlag
+	var packageOutput PackageOutputFlag
+	var flagConcurrencyReport bool
+	var flagPgo string
+	var flagEnvAllowlist string
+	var flagNameSuffix string
+	var flagVCS VCSInfo
+	var flagWorkerGoCache string
+	var flagWorkDir string
+	var flagStateFile string
+	var flagLogDir string
+	var flagResume bool
+	var flagArtifactsDir, flagArtifactsMode string
+	var flagNotifyURL string
+	var flagRace bool
+	var flagCover bool
+	var flagHostFirst, flagSkipHost, flagWatch bool
+	var flagPlatformsFor, flagOutputRoot string
+	var flagDryRun DryRunFlag
+	var flagOutputMode, flagChannel string
+	var flagProgressFD, flagRecentPlatforms, flagExpectCount int
+	var flagIgnoreUnsupported bool
+	var flagStrict bool
+	var flagConfig, flagProfile string
+	var warns warningList
+	var flagCoverMode string
+	var flagBuildVCS string
+	var flagWinSign bool
+	var winSignOpts WinSignOpts
+	var flagPackage string
+	var packageOpts PackageOpts
+	var flagWasmOpt bool
+	var flagWasmOptCmd, flagWasmOptLevel string
+	var flagNotifyTimeout time.Duration
+	var flagBuildTimeout time.Duration
+	var flagManifest, flagSizeBaseline string
+	var flagEmit, flagBaseURL string
+	var flagSizeThreshold float64
 	flags := flag.NewFlagSet("gox", flag.ExitOnError)
 	flags.Usage = func() { printUsage() }
 	flags.Var(platformFlag.ArchFlagValue(), "arch", "arch to build for or skip")
 	flags.Var(platformFlag.OSArchFlagValue(), "osarch", "os/arch pairs to build for or skip")
 	flags.Var(platformFlag.OSFlagValue(), "os", "os to build for or skip")
 	flags.BoolVar(&platformFlag.All, "all", false, "build for all known os/arch combinations")
+	flags.StringVar(&flagOnly, "only", "", "single os/arch pair to build for")
+	flags.StringVar(&flagGoVersions, "go-versions", "", "")
 	flags.StringVar(&ldflags, "ldflags", "", "linker flags")
+	flags.Var(&ldflagFlag, "ldflag", "single linker flag")
 	flags.StringVar(&tags, "tags", "", "go build tags")
 	flags.StringVar(&outputTpl, "output", "{{.Dir}}_{{.OS}}_{{.Arch}}", "output path")
 	flags.IntVar(&parallel, "parallel", -1, "parallelization factor")
@@ -44,18 +98,109 @@ func realMain() int {
 	flags.BoolVar(&verbose, "verbose", false, "verbose")
 	flags.BoolVar(&flagCgo, "cgo", false, "")
 	flags.BoolVar(&flagRebuild, "rebuild", false, "")
+	flags.BoolVar(&flagRace, "race", false, "")
+	flags.BoolVar(&flagCover, "cover", false, "")
+	flags.BoolVar(&flagHostFirst, "host-first", false, "")
+	flags.BoolVar(&flagSkipHost, "skip-host", false, "")
+	flags.BoolVar(&flagWatch, "watch", false, "")
+	flags.StringVar(&flagPlatformsFor, "platforms-for", "", "")
+	flags.StringVar(&flagOutputRoot, "output-root", "", "")
+	flags.Var(&flagDryRun, "dry-run", "")
+	flags.StringVar(&flagOutputMode, "output-mode", "", "")
+	flags.StringVar(&flagChannel, "channel", "", "")
+	flags.IntVar(&flagProgressFD, "progress-fd", 0, "")
+	flags.IntVar(&flagRecentPlatforms, "recent-platforms", 0, "")
+	flags.IntVar(&flagExpectCount, "expect-count", 0, "")
+	flags.BoolVar(&flagIgnoreUnsupported, "ignore-unsupported", false, "")
+	flags.BoolVar(&flagStrict, "strict", false, "")
+	flags.StringVar(&flagConfig, "config", "", "")
+	flags.StringVar(&flagProfile, "profile", "", "")
+	flags.BoolVar(&flagStrict, "warnings-as-errors", false, "")
+	flags.StringVar(&flagCoverMode, "covermode", "", "")
+	flags.StringVar(&flagBuildVCS, "buildvcs", "", "")
+	flags.BoolVar(&flagWinSign, "winsign", false, "")
+	flags.StringVar(&winSignOpts.Cmd, "winsign-cmd", "osslsigncode", "")
+	flags.StringVar(&winSignOpts.PKCS12, "winsign-pkcs12", "", "")
+	flags.StringVar(&winSignOpts.PassFile, "winsign-pass-file", "", "")
+	flags.StringVar(&winSignOpts.TimestampURL, "winsign-timestamp", "", "")
+	flags.StringVar(&flagPackage, "package", "", "")
+	flags.StringVar(&packageOpts.Version, "package-version", "", "")
+	flags.StringVar(&packageOpts.Maintainer, "package-maintainer", "", "")
+	flags.StringVar(&packageOpts.Description, "package-description", "", "")
+	flags.StringVar(&packageOpts.Compression, "package-compression", "", "")
+	flags.BoolVar(&flagWasmOpt, "wasmopt", false, "")
+	flags.StringVar(&flagWasmOptCmd, "wasmopt-cmd", "wasm-opt", "")
+	flags.StringVar(&flagWasmOptLevel, "wasmopt-level", "Oz", "")
 	flags.BoolVar(&flagTrimPath, "trimpath", false, "")
 	flags.BoolVar(&flagListOSArch, "osarch-list", false, "")
+	flags.BoolVar(&flagDumpPlatforms, "dump-platforms-go", false, "")
 	flags.StringVar(&flagBuildmode, "buildmode", "", "")
 	flags.StringVar(&flagGcflags, "gcflags", "", "")
 	flags.StringVar(&flagAsmflags, "asmflags", "", "")
 	flags.StringVar(&flagGoCmd, "gocmd", "go", "")
 	flags.StringVar(&modMode, "mod", "", "")
-	if err := flags.Parse(os.Args[1:]); err != nil {
+	flags.StringVar(&flagListArtifacts, "list-artifacts", "", "")
+	flags.StringVar(&flagAttestSubjects, "attest-subjects", "", "")
+	flags.StringVar(&flagChecksumFormat, "checksum-format", "", "")
+	flags.Var(&outputOverride, "output-override", "")
+	flags.Var(&gcflagsOverride, "gcflags-override", "")
+	flags.Var(&cgoCflagsOverride, "cgo-cflags-override", "")
+	flags.Var(&cgoLdflagsOverride, "cgo-ldflags-override", "")
+	flags.Var(&packageOutput, "package-output", "")
+	flags.BoolVar(&flagConcurrencyReport, "concurrency-report", false, "")
+	flags.StringVar(&flagPgo, "pgo", "", "")
+	flags.StringVar(&flagEnvAllowlist, "env-allowlist", "", "")
+	flags.StringVar(&flagNameSuffix, "name-suffix", "", "")
+	flags.StringVar(&flagVCS.Commit, "commit", "", "")
+	flags.StringVar(&flagVCS.Tag, "tag", "", "")
+	flags.StringVar(&flagWorkerGoCache, "worker-gocache", "", "")
+	flags.StringVar(&flagWorkDir, "work-dir", "", "")
+	flags.StringVar(&flagStateFile, "state-file", "", "")
+	flags.StringVar(&flagLogDir, "log-dir", "", "")
+	flags.BoolVar(&flagResume, "resume", false, "")
+	flags.StringVar(&flagArtifactsDir, "artifacts-dir", "", "")
+	flags.StringVar(&flagArtifactsMode, "artifacts-mode", "copy", "")
+	flags.StringVar(&flagNotifyURL, "notify-url", "", "")
+	flags.DurationVar(&flagNotifyTimeout, "notify-timeout", 10*time.Second, "")
+	flags.DurationVar(&flagBuildTimeout, "build-timeout", 0, "")
+	flags.StringVar(&flagManifest, "manifest", "", "")
+	flags.StringVar(&flagEmit, "emit", "", "")
+	flags.StringVar(&flagBaseURL, "base-url", "", "")
+	flags.StringVar(&flagSizeBaseline, "size-baseline", "", "")
+	flags.Float64Var(&flagSizeThreshold, "size-threshold", 0, "")
+	if err := flags.Parse(args); err != nil {
 		flags.Usage()
 		return 1
 	}
 
+	// Keep stdout to the plan, for other programs to read
+	if flagDryRun == "json" {
+		warns.Stdout = os.Stderr
+	}
+
+	// Fill in the flags that weren't given from the config file. Only an
+	// explicit -config has to exist.
+	configPath := flagConfig
+	if configPath == "" {
+		configPath = defaultConfigFile
+	}
+	config, err := loadConfig(configPath)
+	if os.IsNotExist(err) && flagConfig == "" {
+		config, err = &Config{}, nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config: %s\n", err)
+		return 1
+	}
+	settings, err := config.Resolve(flagProfile)
+	if err == nil {
+		err = applyConfig(flags, settings)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in %s: %s\n", configPath, err)
+		return 1
+	}
+
 	// Determine what amount of parallelism we want Default to the current
 	// number of CPUs-1 is <= 0 is specified.
 	if parallel <= 0 {
@@ -85,14 +230,52 @@ func realMain() int {
 		return 1
 	}
 
-	versionStr, err := GoVersion()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error reading Go version: %s", err)
-		return 1
+	var versionStr string
+	if detected != nil {
+		versionStr = detected.GoVersion
+	} else {
+		versionStr, err = GoVersion()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading Go version: %s", err)
+			return 1
+		}
 	}
 
+	if flagDumpPlatforms {
+		return mainDumpPlatforms(versionStr)
+	}
+
+	// The platforms follow the version of Go that will actually build,
+	// which may be a newer toolchain required by go.mod or go.work.
+	platformsVersion := versionStr
+	var supported []Platform
+	if detected != nil {
+		supported = clonePlatforms(detected.Platforms)
+	} else {
+		platformsVersion = effectiveGoVersion(versionStr, ".", verbose)
+		supported = resolveSupportedPlatforms(platformsVersion, "", verbose)
+	}
+
+	// -platforms-for selects from the platforms of another Go version,
+	// while still building with the Go that is installed
+	if flagPlatformsFor != "" && detected == nil {
+		if _, err := version.NewVersion(strings.TrimPrefix(flagPlatformsFor, "go")); err != nil ||
+			!strings.HasPrefix(flagPlatformsFor, "go") {
+			fmt.Fprintf(os.Stderr, "-platforms-for must be a Go version such as go1.16\n")
+			return 1
+		}
+		platforms, err := platformsOf(flagPlatformsFor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding the platforms of %s: %s\n",
+				flagPlatformsFor, err)
+			return 1
+		}
+
+		platformsVersion = flagPlatformsFor
+		supported = platforms
+	}
 	if flagListOSArch {
-		return mainListOSArch(versionStr)
+		return mainListOSArch(platformsVersion, supported)
 	}
 
 	// Determine the packages that we want to compile. Default to the
@@ -109,8 +292,108 @@ func realMain() int {
 		return 1
 	}
 
-	// Determine the platforms we're building for
-	platforms := platformFlag.Platforms(SupportedPlatforms(versionStr))
+	// Determine the platforms we're building for with each version of
+	// Go. Without -go-versions, that is just the go command on the PATH.
+	toolchains := []string{""}
+	if flagGoVersions != "" {
+		toolchains = parseGoVersions(flagGoVersions)
+	}
+
+	var targets []goTarget
+	var skipped, unsupported []string
+	unsupportedCount := make(map[string]int)
+
+	// Detected platforms are built as they are
+	if detected != nil {
+		toolchains = nil
+		targets = append(targets, goTarget{GoVersion: versionStr, Platforms: supported})
+	}
+	for _, toolchain := range toolchains {
+		target := goTarget{Toolchain: toolchain, GoVersion: versionStr}
+		toolchainSupported := supported
+		if toolchain != "" {
+			target.GoVersion, err = GoToolchainVersion(toolchain)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reading Go version of %s: %s\n", toolchain, err)
+				return 1
+			}
+			if flagPlatformsFor == "" {
+				toolchainSupported = resolveSupportedPlatforms(target.GoVersion, toolchain, verbose)
+			}
+		}
+
+		// -recent-platforms narrows the platforms to choose from down to
+		// the ones added in the last releases
+		candidates := toolchainSupported
+		if flagRecentPlatforms != 0 {
+			v := target.GoVersion
+			if toolchain == "" {
+				v = platformsVersion
+			}
+			candidates, err = recentPlatforms(v, flagRecentPlatforms, toolchainSupported)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				return 1
+			}
+		}
+
+		if flagOnly != "" {
+			target.Platforms, err = platformFlag.Only(flagOnly, candidates)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				return 1
+			}
+		} else {
+			for _, u := range platformFlag.Unsupported(toolchainSupported) {
+				if unsupportedCount[u] == 0 {
+					unsupported = append(unsupported, u)
+				}
+				unsupportedCount[u]++
+			}
+
+			target.Platforms = platformFlag.Platforms(candidates)
+		}
+
+		if flagPlatformsFor != "" {
+			buildable := resolveSupportedPlatforms(target.GoVersion, toolchain, false)
+			if missing := missingPlatforms(target.Platforms, buildable); len(missing) > 0 {
+				warns.Printf("%s can't build these platforms of %s, their builds will fail: %s\n",
+					target.GoVersion, flagPlatformsFor, strings.Join(missing, ", "))
+			}
+		}
+
+		if flagSkipHost {
+			host := Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+			var removed bool
+			target.Platforms, removed = withoutPlatform(target.Platforms, host)
+			if removed && len(skipped) == 0 {
+				skipped = append(skipped, fmt.Sprintf("%s (-skip-host)", host.String()))
+			}
+		}
+
+		targets = append(targets, target)
+	}
+
+	// Fail on platforms that were asked for but that no version of Go
+	// can build, rather than leaving them out without a word
+	if !flagIgnoreUnsupported {
+		var missing []string
+		for _, u := range unsupported {
+			if unsupportedCount[u] == len(targets) {
+				missing = append(missing, u)
+			}
+		}
+		if len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Unsupported platforms requested: %s\n", strings.Join(missing, ", "))
+			if dir := noticeDir(); dir == "" || showNotice(dir, "unsupported-platforms") {
+				fmt.Fprintf(os.Stderr, "Earlier versions of gox left these out silently. "+
+					"Pass -ignore-unsupported to keep doing that.\n")
+			}
+			return 1
+		}
+	}
+
+	platforms := targetPlatforms(targets)
 	if len(platforms) == 0 {
 		fmt.Println("No valid platforms to build for. If you specified a value")
 		fmt.Println("for the 'os', 'arch', or 'osarch' flags, make sure you're")
@@ -118,54 +401,190 @@ func realMain() int {
 		return 1
 	}
 
-	// Assume -mod is supported when no version prefix is found
-	if modMode != "" && strings.HasPrefix(versionStr, "go") {
-		// go-version only cares about version numbers
-		current, err := version.NewVersion(versionStr[2:])
+	// Options are only used if every version of Go supports them
+	gateVersion := versionStr
+	if flagGoVersions != "" {
+		gateVersion = oldestGoVersion(targets)
+	}
+
+	if modMode != "" {
+		ok, err := GoVersionAtLeast(gateVersion, "1.11")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to parse current go version: %s\n%s", versionStr, err.Error())
+			fmt.Fprintf(os.Stderr, "%s", err)
 			return 1
 		}
+		if !ok {
+			warns.Printf("Go compiler version %s does not support the -mod flag\n", gateVersion)
+			modMode = ""
+		}
+	}
 
-		constraint, err := version.NewConstraint(">= 1.11")
+	// Profile-guided optimization, including per-platform profiles set
+	// through the environment, requires Go 1.21.
+	pgoSupported, err := GoVersionAtLeast(gateVersion, "1.21")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", err)
+		return 1
+	}
+	if flagPgo != "" && !pgoSupported {
+		warns.Printf("Go compiler version %s does not support the -pgo flag, ignoring it\n", gateVersion)
+		flagPgo = ""
+	}
+
+	if flagBuildVCS != "" {
+		ok, err := GoVersionAtLeast(gateVersion, "1.18")
 		if err != nil {
-			panic(err)
+			fmt.Fprintf(os.Stderr, "%s", err)
+			return 1
+		}
+		if !ok {
+			warns.Printf("Go compiler version %s does not support the -buildvcs flag, ignoring it\n", gateVersion)
+			flagBuildVCS = ""
 		}
+	}
 
-		if !constraint.Check(current) {
-			fmt.Printf("Go compiler version %s does not support the -mod flag\n", versionStr)
-			modMode = ""
+	// Like go build, -covermode implies -cover
+	if flagCoverMode != "" {
+		flagCover = true
+	}
+	if flagCover {
+		ok, err := GoVersionAtLeast(gateVersion, "1.20")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", err)
+			return 1
+		}
+		if !ok {
+			warns.Printf("Go compiler version %s does not support building binaries with -cover, ignoring it\n", gateVersion)
+			flagCover = false
+			flagCoverMode = ""
 		}
 	}
 
-	// Build in parallel!
-	fmt.Printf("Number of parallel builds: %d\n\n", parallel)
-	var errorLock sync.Mutex
-	var wg sync.WaitGroup
-	errors := make([]string, 0)
-	semaphore := make(chan int, parallel)
-	for _, platform := range platforms {
-		for _, path := range mainDirs {
-			// Start the goroutine that will do the actual build
-			wg.Add(1)
-			go func(path string, platform Platform) {
-				defer wg.Done()
-				semaphore <- 1
-				fmt.Printf("--> %15s: %s\n", platform.String(), path)
-
-				opts := &CompileOpts{
-					PackagePath: path,
-					Platform:    platform,
-					OutputTpl:   outputTpl,
-					Ldflags:     ldflags,
-					Gcflags:     flagGcflags,
-					Asmflags:    flagAsmflags,
-					Tags:        tags,
-					ModMode:     modMode,
-					Cgo:         flagCgo,
-					Rebuild:     flagRebuild,
-					TrimPath:    flagTrimPath,
-					GoCmd:       flagGoCmd,
+	for _, target := range targets {
+		var experimental []string
+		for _, p := range target.Platforms {
+			if p.Experimental(target.GoVersion) {
+				experimental = append(experimental, p.String())
+			}
+		}
+		if len(experimental) > 0 {
+			warns.Printf("These ports are experimental in %s and may be unstable: %s\n",
+				target.GoVersion, strings.Join(experimental, ", "))
+		}
+	}
+
+	if flagRace {
+		for _, target := range targets {
+			var unsupported []string
+			for _, p := range target.Platforms {
+				if !p.SupportsRace(target.GoVersion) {
+					unsupported = append(unsupported, p.String())
+				}
+			}
+			if len(unsupported) > 0 {
+				warns.Printf("The race detector isn't supported on %s with %s, building without -race\n",
+					strings.Join(unsupported, ", "), target.GoVersion)
+			}
+		}
+	}
+
+	// Only restrict the inherited environment if an allowlist was given
+	var envAllowlist []string
+	if flagEnvAllowlist != "" {
+		envAllowlist = make([]string, 0)
+		for _, k := range strings.Split(flagEnvAllowlist, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				envAllowlist = append(envAllowlist, k)
+			}
+		}
+	}
+
+	// Read the commit and tag once for every build. A missing git or a
+	// source tree outside of a repository is not an error.
+	vcs := resolveVCSInfo("", flagVCS, verbose)
+
+	// The options shared by every build. Each build gets its own copy
+	// with the package, platform and per-platform overrides filled in.
+	baseOpts := CompileOpts{
+		OutputTpl:  outputTpl,
+		Ldflags:    ldflagFlag.Join(ldflags),
+		Gcflags:    flagGcflags,
+		Asmflags:   flagAsmflags,
+		Tags:       tags,
+		ModMode:    modMode,
+		Cgo:        flagCgo,
+		Rebuild:    flagRebuild,
+		Race:       flagRace,
+		Cover:      flagCover,
+		CoverMode:  flagCoverMode,
+		BuildVCS:   flagBuildVCS,
+		Buildmode:  flagBuildmode,
+		TrimPath:   flagTrimPath,
+		GoCmd:      flagGoCmd,
+		Pgo:        flagPgo,
+		NameSuffix: flagNameSuffix,
+		GoVersion:  versionStr,
+		VCS:        vcs,
+		Channel:    flagChannel,
+
+		EnvAllowlist: envAllowlist,
+	}
+
+	// Report every invalid combination of options at once, rather than
+	// failing the same way in the middle of many builds.
+	if errs := ValidateOptions(&baseOpts, platforms); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%d errors in the build options:\n", len(errs))
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "--> %s\n", err)
+		}
+		return 1
+	}
+
+	// The logs are written by go build, which may run in another
+	// directory, so the log directory must be absolute.
+	if flagLogDir != "" {
+		flagLogDir, err = filepath.Abs(flagLogDir)
+		if err == nil {
+			err = os.MkdirAll(flagLogDir, 0755)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating -log-dir: %s\n", err)
+			return 1
+		}
+	}
+
+	// Plan every build up front so that problems such as two builds
+	// writing to the same path are caught before anything is compiled.
+	jobs := make([]*CompileOpts, 0, len(platforms)*len(mainDirs))
+	for _, target := range targets {
+		for _, platform := range target.Platforms {
+			for _, path := range mainDirs {
+				opts := new(CompileOpts)
+				*opts = baseOpts
+				opts.PackagePath = path
+				opts.Platform = platform
+				opts.GoVersion = target.GoVersion
+				opts.GoToolchain = target.Toolchain
+				if tpl, ok := packageOutput.Lookup(path); ok {
+					opts.OutputTpl = tpl
+				}
+				opts.OutputName, _ = outputOverride.Lookup(platform)
+				opts.PlatformGcflags, _ = gcflagsOverride.Lookup(platform)
+				opts.CgoCflags, _ = cgoCflagsOverride.Lookup(platform)
+				opts.CgoLdflags, _ = cgoLdflagsOverride.Lookup(platform)
+
+				// Keep the outputs of each Go version apart
+				if len(targets) > 1 && !outputUsesGoVersion(opts) {
+					opts.NameSuffix += "_{{.GoVersion}}"
+				}
+
+				if flagLogDir != "" {
+					var logVersion string
+					if len(targets) > 1 {
+						logVersion = target.GoVersion
+					}
+					opts.LogFile = filepath.Join(flagLogDir,
+						logFileName(platform, path, len(mainDirs) > 1, logVersion))
 				}
 
 				// Determine if we have specific CFLAGS or LDFLAGS for this
@@ -175,27 +594,494 @@ func realMain() int {
 				envOverride(&opts.Asmflags, platform, "ASMFLAGS")
 				envOverride(&opts.Cc, platform, "CC")
 				envOverride(&opts.Cxx, platform, "CXX")
+				if pgoSupported {
+					envOverride(&opts.Pgo, platform, "PGO")
+				}
+
+				jobs = append(jobs, opts)
+			}
+		}
+	}
+
+	if err := checkOutputCollisions(jobs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	if err := checkOutputDirConflicts(jobs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	if flagOutputRoot != "" {
+		if err := checkOutputRoot(flagOutputRoot, jobs); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+	}
+	for _, w := range checkOutputTemplates(jobs) {
+		warns.Printf("Warning: %s\n", w)
+	}
+	if err := checkPgoProfiles(jobs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if flagArtifactsMode != "copy" && flagArtifactsMode != "move" {
+		fmt.Fprintf(os.Stderr, "-artifacts-mode must be copy or move\n")
+		return 1
+	}
+
+	// Read the baseline up front, so that a typo doesn't waste a build
+	var sizeBaseline *Summary
+	if flagSizeBaseline != "" {
+		sizeBaseline, err = readManifest(flagSizeBaseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading -size-baseline: %s\n", err)
+			return 1
+		}
+	}
+
+	if flagEmit != "" {
+		if _, ok := emitFormats[flagEmit]; !ok {
+			fmt.Fprintf(os.Stderr, "-emit must be brew or scoop\n")
+			return 1
+		}
+		if flagBaseURL == "" {
+			fmt.Fprintf(os.Stderr, "-emit requires -base-url\n")
+			return 1
+		}
+	}
 
-				if err := GoCrossCompile(opts); err != nil {
-					errorLock.Lock()
-					defer errorLock.Unlock()
-					errors = append(errors,
-						fmt.Sprintf("%s error: %s", platform.String(), err))
+	if flagExpectCount < 0 {
+		fmt.Fprintf(os.Stderr, "-expect-count must be a number of builds\n")
+		return 1
+	}
+
+	if flagResume && flagStateFile == "" {
+		fmt.Fprintf(os.Stderr, "-resume requires -state-file\n")
+		return 1
+	}
+
+	if flagWinSign {
+		if winSignOpts.PKCS12 == "" {
+			fmt.Fprintf(os.Stderr, "-winsign requires -winsign-pkcs12\n")
+			return 1
+		}
+		if _, err := os.Stat(winSignOpts.PKCS12); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading -winsign-pkcs12: %s\n", err)
+			return 1
+		}
+	}
+
+	var checksumFormats []string
+	if flagChecksumFormat != "" {
+		checksumFormats, err = parseChecksumFormats(flagChecksumFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+	}
+
+	var outputMode os.FileMode
+	if flagOutputMode != "" {
+		outputMode, err = parseOutputMode(flagOutputMode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+		packageOpts.Mode = outputMode
+	}
+
+	if flagPackage != "" {
+		packageOpts.Formats, err = parsePackageFormats(flagPackage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+
+		packageOpts.Compression, err = parsePackageCompression(packageOpts.Compression)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+
+		// Tarballs aren't versioned, only the Linux packages are
+		linuxPackages := false
+		for _, f := range packageOpts.Formats {
+			if _, ok := packageArches[f]; ok {
+				linuxPackages = true
+			}
+		}
+
+		pkgVersion := packageOpts.Version
+		if pkgVersion == "" {
+			pkgVersion = vcs.Tag
+		}
+		if pkgVersion == "" && linuxPackages {
+			fmt.Fprintf(os.Stderr, "-package requires -package-version when HEAD isn't tagged\n")
+			return 1
+		}
+		if pkgVersion != "" {
+			packageOpts.Version, err = packageVersion(pkgVersion)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				return 1
+			}
+		}
+	} else if packageOpts.Compression != "" {
+		fmt.Fprintf(os.Stderr, "-package-compression requires -package=tgz\n")
+		return 1
+	}
+
+	// Post-process each build in its worker. The order matters: anything
+	// that changes the binary, like signing, must come before hashing.
+	// planOpts describes the same steps for -dry-run.
+	var steps postBuildSteps
+	plan := planOpts{
+		Channel:      flagChannel,
+		ArtifactsDir: flagArtifactsDir,
+		Checksums:    checksumFormats,
+	}
+	if flagWinSign {
+		steps = append(steps, winSignStep(winSignOpts))
+		plan.WinSign = true
+	}
+	if flagWasmOpt && hasWasmPlatform(platforms) {
+		if _, err := exec.LookPath(flagWasmOptCmd); err != nil {
+			warns.Printf("%s isn't on the PATH, wasm modules won't be optimized\n", flagWasmOptCmd)
+		} else {
+			steps = append(steps, wasmOptStep(flagWasmOptCmd, flagWasmOptLevel))
+			plan.WasmOpt = true
+		}
+	}
+	if outputMode != 0 {
+		steps = append(steps, outputModeStep(outputMode))
+		plan.OutputMode = true
+	}
+	if len(packageOpts.Formats) > 0 {
+		steps = append(steps, packageStep(packageOpts))
+		plan.PackageFormats = packageOpts.Formats
+	}
+
+	if flagDryRun != "" {
+		plan, err := newBuildPlan(jobs, plan, func(opts *CompileOpts) []string {
+			return opts.Platform.TargetEnv(opts)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+
+		if flagDryRun == "json" {
+			err = writeBuildPlan(os.Stdout, plan)
+		} else {
+			printBuildPlan(os.Stdout, plan, jobs)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing the build plan: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	// GOCACHE must be an absolute path
+	if flagWorkerGoCache != "" {
+		flagWorkerGoCache, err = filepath.Abs(flagWorkerGoCache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving -worker-gocache: %s\n", err)
+			return 1
+		}
+	}
+
+	// Everything a build writes goes to the work directory first, and
+	// the directory is removed however gox exits.
+	var workDir string
+	if flagWorkDir != "" {
+		workDir, err = newWorkDir(flagWorkDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating -work-dir: %s\n", err)
+			return 1
+		}
+		defer os.RemoveAll(workDir)
+
+		for _, opts := range jobs {
+			opts.WorkDir = workDir
+		}
+	}
+
+	// An interrupt cancels the builds, and the run then ends as usual,
+	// with the builds recorded as cancelled. A second one exits at once.
+	interrupted, stopInterrupt := cancelOnInterrupt(func() {
+		if workDir != "" {
+			os.RemoveAll(workDir)
+		}
+	})
+	defer stopInterrupt()
+
+	// Record the builds that succeed, so that an interrupted run can be
+	// resumed. Builds recorded by the previous run are only reused with
+	// -resume, otherwise the state starts over.
+	var state *runState
+	var resumed []BuildResult
+	if flagStateFile != "" {
+		state = newRunState(flagStateFile)
+		if flagResume {
+			state, err = loadRunState(flagStateFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading -state-file: %s\n", err)
+				return 1
+			}
+
+			remaining := jobs[:0]
+			for _, opts := range jobs {
+				if r, ok := state.Resume(opts); ok {
+					fmt.Printf("--> %15s: %s (resumed)\n", opts.Platform.String(), opts.PackagePath)
+					resumed = append(resumed, r)
+					continue
 				}
-				<-semaphore
-			}(path, platform)
+
+				remaining = append(remaining, opts)
+			}
+			jobs = remaining
+		}
+	}
+
+	if flagWatch && len(jobs) > watchMaxBuilds {
+		warns.Printf("-watch rebuilds all %d builds on every change; "+
+			"consider fewer platforms with -osarch\n", len(jobs))
+	}
+
+	// Warnings so far are about the options, so fail before building
+	if flagStrict {
+		if n := len(warns.Warnings()); n > 0 {
+			fmt.Fprintf(os.Stderr, "\n%d warnings treated as errors because of -strict\n", n)
+			return 1
+		}
+	}
+
+	if len(skipped) > 0 {
+		fmt.Println("Skipped platforms:")
+		for _, s := range skipped {
+			fmt.Printf("    %s\n", s)
+		}
+		fmt.Println()
+	}
+
+	// Build in parallel!
+	fmt.Printf("Number of parallel builds: %d\n\n", parallel)
+
+	// -watch only builds: nothing is post-processed, recorded in the
+	// -state-file or reported to -progress-fd
+	if flagWatch {
+		builder := &Builder{
+			Parallel:      parallel,
+			WorkerGoCache: flagWorkerGoCache,
+			Cancel:        interrupted,
+			Timeout:       flagBuildTimeout,
+		}
+
+		root, err := watchRoot(".")
+		if err == nil {
+			err = watchBuilds(root, func() []BuildResult {
+				return builder.Build(jobs)
+			}, os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error watching for changes: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	builder := &Builder{
+		Parallel:      parallel,
+		WorkerGoCache: flagWorkerGoCache,
+		Cancel:        interrupted,
+		Timeout:       flagBuildTimeout,
+		PostBuild:     steps.Run,
+	}
+
+	// Progress events go to their own file descriptor, which is closed
+	// when gox exits to tell the reader that the run is over
+	var progress *progressWriter
+	if flagProgressFD != 0 {
+		f, err := openProgressFD(flagProgressFD)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+		defer f.Close()
+
+		progress = newProgressWriter(f)
+		builder.OnStart = progress.Started
+		for _, r := range resumed {
+			progress.Finished(r)
+		}
+	}
+
+	if state != nil || progress != nil {
+		builder.OnResult = func(r BuildResult) {
+			if progress != nil {
+				progress.Finished(r)
+			}
+			if state == nil || r.Err != nil {
+				return
+			}
+			if err := state.Record(r); err != nil {
+				warns.Fprintf(os.Stderr, "Warning: recording %s in -state-file: %s\n", r.Platform.String(), err)
+			}
+		}
+	}
+	var results []BuildResult
+	if flagHostFirst {
+		results = builder.BuildHostFirst(jobs)
+	} else {
+		results = builder.Build(jobs)
+	}
+	results = append(resumed, results...)
+
+	if progress != nil {
+		// Skipped builds never ran, so they haven't been reported yet
+		for _, r := range results {
+			if r.status() == StatusSkipped {
+				progress.Finished(r)
+			}
+		}
+		if err := progress.Err(); err != nil {
+			warns.Fprintf(os.Stderr, "Warning: writing to -progress-fd: %s\n", err)
+		}
+	}
+
+	errors := make([]string, 0)
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+
+		if len(targets) > 1 {
+			errors = append(errors,
+				fmt.Sprintf("%s (%s) error: %s", r.Platform.String(), r.GoVersion, r.Err))
+		} else {
+			errors = append(errors,
+				fmt.Sprintf("%s error: %s", r.Platform.String(), r.Err))
+		}
+	}
+
+	if flagExpectCount > 0 {
+		if err := checkBuildCount(flagExpectCount, results); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	if len(targets) > 1 {
+		printGoVersionReport(os.Stdout, results)
+	}
+
+	if flagConcurrencyReport {
+		builder.Stats.Report(os.Stdout)
+	}
+
+	// Errors from here on aren't specific to a platform, but they are
+	// reported along with the build errors all the same.
+	if flagArtifactsDir != "" {
+		move := flagArtifactsMode == "move"
+		err := collectArtifacts(flagArtifactsDir, move, results)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("collecting artifacts: %s", err))
+		}
+
+		// Moved artifacts are only found again by -resume where they are now
+		if move && state != nil {
+			if err := state.Relocate(results); err != nil {
+				warns.Fprintf(os.Stderr, "Warning: updating -state-file: %s\n", err)
+			}
+		}
+	}
+
+	// Checksum files are artifacts of the run rather than of a build
+	var runArtifacts []string
+	digests := make(artifactDigests)
+	if len(checksumFormats) > 0 {
+		dir := flagArtifactsDir
+		if dir == "" {
+			dir = "."
+		}
+		paths, err := writeChecksums(dir, checksumFormats, results, digests)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("writing checksums: %s", err))
+		}
+		runArtifacts = append(runArtifacts, paths...)
+	}
+
+	if flagListArtifacts != "" {
+		if err := writeArtifactList(flagListArtifacts, results, runArtifacts); err != nil {
+			errors = append(errors, fmt.Sprintf("writing artifact list: %s", err))
+		}
+	}
+
+	if flagEmit != "" {
+		fmt.Println()
+		if err := emitPackages(os.Stdout, flagEmit, flagBaseURL, results); err != nil {
+			errors = append(errors, fmt.Sprintf("emitting %s: %s", flagEmit, err))
+		}
+	}
+
+	summary := NewSummary(versionStr, results)
+	summary.Channel = flagChannel
+	summary.Artifacts = runArtifacts
+	if sizeBaseline != nil {
+		deltas := compareSizes(sizeBaseline, summary)
+		printSizeDeltas(os.Stdout, flagSizeBaseline, deltas)
+		for _, d := range deltas {
+			if flagSizeThreshold > 0 && d.Percent() > flagSizeThreshold {
+				errors = append(errors, fmt.Sprintf(
+					"%s: %s grew by %.2f%%, more than -size-threshold",
+					d.Platform, d.build(), d.Percent()))
+			}
+		}
+	}
+
+	if flagAttestSubjects != "" {
+		if err := writeAttestSubjects(flagAttestSubjects, summary, digests); err != nil {
+			errors = append(errors, fmt.Sprintf("writing attestation subjects: %s", err))
+		}
+	}
+
+	if flagManifest != "" {
+		summary.Success = len(errors) == 0
+		if err := writeManifest(flagManifest, summary); err != nil {
+			errors = append(errors, fmt.Sprintf("writing manifest: %s", err))
 		}
 	}
-	wg.Wait()
 
 	if len(errors) > 0 {
 		fmt.Fprintf(os.Stderr, "\n%d errors occurred:\n", len(errors))
 		for _, err := range errors {
 			fmt.Fprintf(os.Stderr, "--> %s\n", err)
 		}
+	}
+
+	// Notifying is best effort and never changes the outcome of the run
+	if flagNotifyURL != "" {
+		summary.Success = len(errors) == 0
+		if err := notify(flagNotifyURL, flagNotifyTimeout, summary); err != nil {
+			warns.Fprintf(os.Stderr, "Warning: notifying %s failed: %s\n", flagNotifyURL, err)
+		}
+	}
+
+	if len(errors) > 0 {
 		return 1
 	}
 
+	if flagStrict {
+		if n := len(warns.Warnings()); n > 0 {
+			fmt.Fprintf(os.Stderr, "\n%d warnings treated as errors because of -strict:\n", n)
+			for _, w := range warns.Warnings() {
+				fmt.Fprintf(os.Stderr, "--> %s\n", w)
+			}
+			return 1
+		}
+	}
+
 	return 0
 }
 
@@ -215,20 +1101,142 @@ Options:
   -arch=""            Space-separated list of architectures to build for
   -build-toolchain    Build cross-compilation toolchain
   -cgo                Sets CGO_ENABLED=1, requires proper C toolchain (advanced)
+  -buildmode=""       Additional '-buildmode' value to pass to go build
+  -buildvcs=""        '-buildvcs' value (true, false or auto) to pass to go
+                      build, for Go 1.18 and later
   -gcflags=""         Additional '-gcflags' value to pass to go build
+  -gcflags-override=""
+                      Per-platform gcflags merged with -gcflags, as
+                      os/arch=flags. See below for more info
+  -cgo-cflags-override=""
+                      Per-platform flags added to CGO_CFLAGS for cgo builds,
+                      as os/arch=flags. See below for more info
+  -cgo-ldflags-override=""
+                      Per-platform flags added to CGO_LDFLAGS for cgo builds,
+                      as os/arch=flags. See below for more info
   -ldflags=""         Additional '-ldflags' value to pass to go build
+  -ldflag=""          A single linker flag, such as '-X main.version=1.0',
+                      added to -ldflags. May be given multiple times
   -asmflags=""        Additional '-asmflags' value to pass to go build
   -tags=""            Additional '-tags' value to pass to go build
+  -pgo=""             Profile for profile-guided optimization (Go 1.21+)
   -mod=""             Additional '-mod' value to pass to go build
   -os=""              Space-separated list of operating systems to build for
   -osarch=""          Space-separated list of os/arch pairs to build for
+  -only=""            Build for exactly this os/arch pair, for example
+                      "linux/amd64". Can't be combined with -os, -arch,
+                      -osarch or -all
+  -expect-count=0     Fail unless exactly this many builds succeed, to catch
+                      platforms dropping out of the matrix unnoticed
+  -recent-platforms=0 Only build the platforms added in this many of the
+                      latest Go releases, such as 2 for the ports new in
+                      the last two. Can be narrowed with -os and -arch
+  -go-versions=""     Build with each of these GOTOOLCHAIN values, such as
+                      "go1.21.4,go1.22.1". See below for more info
   -osarch-list        List supported os/arch pairs for your Go version
+  -dump-platforms-go  Print a Go platform table for your Go version, in the
+                      form used by gox's source, with first class ports as
+                      the defaults. Requires Go 1.13 or later
   -all                Build for all know os/arch combinations
   -output="foo"       Output path template. See below for more info
-  -parallel=-1        Amount of parallelism, defaults to number of CPUs
+  -commit=""          Commit for the output template, instead of asking git
+  -tag=""             Tag for the output template, instead of asking git
+  -channel=""         Release channel, such as "beta", for the output
+                      template and the manifest
+  -name-suffix=""     Template appended to every output file name, before
+                      the ".exe" or ".wasm" extension
+  -package-output=""  Output path template for a single package, as
+                      package=template, e.g. "./cmd/ctl={{.Name}}_{{.OS}}"
+  -output-override="" Per-platform output file name, as os/arch=name. The
+                      os/arch may be a glob, e.g. "windows/*=setup.exe"
+  -dry-run            Print the builds, and what runs after each, without
+                      running them. -dry-run=json prints them as JSON, see
+                      "Dry Run" below
+  -progress-fd=0      Write build progress as JSON lines to this file
+                      descriptor, see "Progress" below
+  -output-mode=""     Permissions of the binaries, in octal such as 0755,
+                      whatever the umask. Also used for the binary in the
+                      -package packages. By default the umask decides
+  -output-root=""     Fail before building if any output would be written
+                      outside of this directory, after resolving ".." and
+                      symlinks. Not checked unless set
+  -parallel=-1        Amount of parallelism, defaults to number of CPUs.
+                      Post-processing, such as signing, runs as part of each
+                      build, overlapping with the other builds
+  -build-timeout=0    Kill a build that takes longer than this, such as 10m,
+                      and report it as timed out. No limit by default
   -gocmd="go"         Build command, defaults to Go
+  -worker-gocache=""  Give each parallel worker its own GOCACHE under this
+                      directory. See below for more info
+  -work-dir=""        Build in a temporary directory below this one, for
+                      example on a tmpfs. See below for more info
+  -env-allowlist=""   Comma-separated list of environment variables to pass
+                      to go build. By default the whole environment is passed
+  -concurrency-report Print worker pool utilization after building, to help
+                      tune -parallel
+  -artifacts-dir=""   Collect the final artifacts into this directory
+  -artifacts-mode="copy"
+                      Whether -artifacts-dir copies or moves the artifacts
+  -checksum-format="" Write checksums of the artifacts: sha256, sha512 and/or
+                      json, comma-separated. See Artifacts below
+  -manifest=""        Write a JSON summary of the run, including the size of
+                      each output, to this file. See Notifications below
+  -size-baseline=""   Print the size changes of the outputs since the builds
+                      in this manifest from an earlier run
+  -size-threshold=0   With -size-baseline, fail if an output grew by more
+                      than this many percent
+  -emit=""            Print the download URLs and SHA-256 hashes of the
+                      artifacts for a Homebrew formula (brew) or Scoop
+                      manifest (scoop). See Artifacts below
+  -base-url=""        URL the artifacts are published at, for -emit
+  -notify-url=""      POST a JSON summary to this URL when the run finishes
+  -notify-timeout=10s Timeout for each attempt to notify -notify-url
+  -winsign            Sign windows binaries with osslsigncode. See below
+  -package=""         Package binaries as deb, rpm and/or tgz. See below
+  -state-file=""      Record the successful builds of this run in this file
+  -resume             Reuse the builds recorded in -state-file whose
+                      artifacts are unchanged, to resume an interrupted run
+  -wasmopt            Optimize wasm modules with Binaryen's wasm-opt after
+                      building. Skipped with a warning if it isn't installed
+  -wasmopt-level="Oz" wasm-opt optimization level, such as O3 or Oz
+  -wasmopt-cmd="wasm-opt"
+                      wasm-opt command to run
+  -list-artifacts=""  Write the final artifact paths, one per line, to this
+                      file after building ("-" for stdout)
+  -attest-subjects="" Write the in-toto subjects of the artifacts to this
+                      file. See Artifacts below
+  -race               Enable the race detector where supported. The output
+                      names of race-enabled builds end in "_race"
+  -host-first         Build for the host platform first, and only build the
+                      other platforms if that succeeds
+  -ignore-unsupported Leave out requested platforms the Go version doesn't
+                      support, instead of failing
+  -watch              Rebuild whenever a Go source file, go.mod or go.sum of
+                      the module changes, until interrupted. Only builds;
+                      artifacts, manifests and the like are skipped
+  -platforms-for=""   Choose the platforms from those of this Go version, such
+                      as go1.16, instead of the installed one, to reproduce
+                      the platforms of an old release. Releases newer than
+                      gox's tables are asked through GOTOOLCHAIN. Still
+                      builds with the installed Go
+  -skip-host          Don't build for the host platform, for when a host
+                      build already exists
+  -cover              Build coverage-instrumented binaries (Go 1.20+), which
+                      write coverage data to $GOCOVERDIR when run
+  -covermode=""       Coverage mode: set, count or atomic. Implies -cover
   -rebuild            Force rebuilding of package that were up to date
   -trimpath			  Remove all file system paths from the resulting executable
+  -log-dir=""         Write the output of each go build to a log file in
+                      this directory, named [OS]_[ARCH].log. When building
+                      several packages, the import path of the package is
+                      appended, with "/" turned into "_"
+  -strict             Exit with an error if there were any warnings, such as
+                      an option being ignored. Warnings about the options
+                      stop the run before building. Alias:
+                      -warnings-as-errors
+  -config=".gox.yml"  Config file with default values for the flags. See
+                      below for more info
+  -profile=""         Profile of the config file to use
   -verbose            Verbose mode
 
 Output path template:
@@ -236,8 +1244,114 @@ Output path template:
   The output path for the compiled binaries is specified with the
   "-output" flag. The value is a string that is a Go text template.
   The default value is "{{.Dir}}_{{.OS}}_{{.Arch}}". Other available
-  variables are OSUname and ArchUname which should correspond to uname -s
-  and uname -m respectively.
+  variables are Name, the name of the binary of the package (usually the
+  same as Dir, but without any major version suffix such as "/v2"),
+  OSUname and ArchUname which should correspond to uname -s
+  and uname -m respectively, and GoVersion which is the version of Go
+  doing the build, such as "go1.21.4". Commit and Tag are the commit and
+  tag of HEAD as reported by git; they are empty if git isn't available or
+  HEAD isn't tagged, and can be given explicitly with "-commit" and "-tag".
+  Channel is the release channel given with "-channel". When it's empty,
+  one "_" or "-" next to it is dropped too, so that
+  "{{.Dir}}_{{.Channel}}_{{.OS}}_{{.Arch}}" gives myapp_beta_linux_amd64
+  with "-channel=beta" and myapp_linux_amd64 without.
+  The "env" function returns the value of an environment variable, for
+  example {{env "BUILD_DATE"}}.
+
+  The "-name-suffix" flag is a lighter way to tag every output, for example
+  -name-suffix='_nightly-{{env "BUILD_DATE"}}'. It is a template with the
+  same variables as "-output" and is inserted before any ".exe" or ".wasm"
+  extension.
+
+  When building several packages, each package can have its own output
+  template with "-package-output", for example:
+
+    -package-output='./cmd/ctl=dist/myctl_{{.OS}}_{{.Arch}}'
+    -package-output='./cmd/daemon=dist/myd_{{.OS}}_{{.Arch}}'
+
+  The package is an import path, or a path starting with "./" matching
+  the end of an import path. Packages without a template use "-output".
+
+  The "-output-override" flag replaces the file name (but not the
+  directory) of the rendered output for matching platforms. It may be
+  given multiple times; the last matching pattern wins.
+
+Dry Run:
+
+  "-dry-run" prints each build as a shell command, with the variables gox
+  sets, and the steps that run after it. "-dry-run=json" prints the whole
+  plan as JSON instead, for other programs to run or audit the builds:
+
+    {
+      "version": 1,
+      "channel": "beta",
+      "builds": [
+        {
+          "platform": "linux/amd64",
+          "package": "github.com/mitchellh/gox",
+          "go_version": "go1.21.4",
+          "argv": ["go", "build", "-ldflags", "", ...],
+          "env": ["HOME=/home/gopher", ..., "GOOS=linux", ...],
+          "output": "gox_linux_amd64",
+          "steps": ["package:deb"]
+        }
+      ],
+      "artifacts_dir": "dist",
+      "checksums": ["dist/checksums.txt"]
+    }
+
+  "channel" is the -channel, if any. A build that runs in another
+  directory has a "dir" with it.
+  "env" is the complete environment of the command, including what is
+  inherited. "steps" are winsign, wasmopt, chmod and package:<format>, in
+  the order they run. Fields may be added, but "version" changes if any
+  are removed or change meaning. Warnings go to stderr.
+
+  The plan is made before -work-dir and -resume are applied, so builds
+  are shown writing to their final outputs, and all of them are shown.
+
+Progress:
+
+  With "-progress-fd", gox writes a JSON object per line to the given file
+  descriptor, which must be open, as each build starts and finishes:
+
+    {"event":"started","time":"2024-01-15T10:00:00Z",
+     "platform":"linux/amd64","package":"github.com/mitchellh/gox",
+     "go_version":"go1.21.4"}
+    {"event":"finished","time":"2024-01-15T10:00:04Z",
+     "platform":"linux/amd64","package":"github.com/mitchellh/gox",
+     "go_version":"go1.21.4","status":"success","duration_ms":4210}
+
+  "status" is one of the statuses of the manifest, and failed builds have
+  an "error". Builds that are skipped or reused with -resume only have a
+  "finished" event. The descriptor is closed when gox exits. For example,
+  from a shell:
+
+    gox -progress-fd=3 3>progress.json
+
+Config File:
+
+  Flags that are used for every build can be kept in a YAML config file,
+  ".gox.yml" in the current directory unless "-config" is given. Settings
+  are named after the flags, without the dash. A list sets a flag once per
+  item, for flags that may be given multiple times. Named profiles override
+  the base settings for different workflows and are selected with
+  "-profile":
+
+    osarch: linux/amd64 darwin/arm64 windows/amd64
+    ldflags: -s -w
+    profiles:
+      dev:
+        only: linux/amd64
+        osarch: []
+      release:
+        all: true
+        trimpath: true
+
+  The precedence, from lowest to highest, is: the base settings, then the
+  selected profile, then the flags given on the command line. Flags that
+  select platforms (-os, -arch, -osarch, -all, -only and -recent-platforms)
+  replace all of those of the config when any is given on the command line.
 
 Platforms (OS/Arch):
 
@@ -258,6 +1372,174 @@ Platforms (OS/Arch):
   built even if the specific os and arch is negated in "-os" and "-arch",
   respectively.
 
+  The supported platforms are those of the Go version that will build. If
+  the go.work or go.mod of the current directory requires a newer Go, with
+  its "go" or "toolchain" directive, the go command switches to that
+  toolchain and so its platforms are used. Set GOTOOLCHAIN=local to always
+  use the platforms of the go command on the PATH.
+
+  Asking for a platform that isn't supported with "-osarch", or an OS or
+  architecture without any supported platform with "-os" or "-arch", is an
+  error. Combinations of "-os" and "-arch" values are only built where
+  supported. Pass "-ignore-unsupported" to leave out unsupported platforms
+  instead, as earlier versions of gox did.
+
+  To build with several versions of Go in one run, list their GOTOOLCHAIN
+  values with "-go-versions". The go command downloads the toolchains as
+  needed. Every version builds for the platforms it supports out of those
+  selected, and options that some version doesn't support are ignored for
+  all of them. Unless the output template or "-name-suffix" uses
+  {{.GoVersion}}, "_{{.GoVersion}}" is appended to the output names to keep
+  the versions apart.
+
+Artifacts:
+
+  The output template decides where each build is written. The final
+  shippable files can additionally be gathered into a single directory with
+  "-artifacts-dir", for example to upload them. By default the artifacts are
+  copied, leaving the build outputs where they are; "-artifacts-mode=move"
+  moves them instead. Artifacts keep their file names, so they must be
+  unique. "-list-artifacts" lists the artifacts at their final location.
+
+  "-checksum-format" writes checksums of the artifacts to the artifacts
+  directory, or the current directory without "-artifacts-dir". Each format
+  is its own file: "sha256" writes checksums.txt and "sha512" writes
+  checksums.sha512, in the format of sha256sum and sha512sum, and "json"
+  writes checksums.json, mapping each artifact to its "sha256" and "sha512".
+  Give several, such as "-checksum-format=sha256,json", to write several.
+  Artifacts are named by their path relative to that directory. The
+  checksum files are artifacts too: "-list-artifacts", "-attest-subjects"
+  and the "artifacts" of the "-manifest" include them.
+
+  To publish through a package manager, "-emit" prints what its formula or
+  manifest needs to download the artifacts. The URL of an artifact is the
+  "-base-url" followed by its file name. "-emit=brew" prints on_macos and
+  on_linux blocks for Homebrew, for amd64 (on_intel) and arm64 (on_arm).
+  "-emit=scoop" prints the "architecture" object of a Scoop manifest, for
+  windows on 386 (32bit), amd64 (64bit) and arm64. Other platforms are left
+  out. There is one section per package.
+
+  For attestations, "-attest-subjects" writes the subjects of an in-toto
+  statement for the artifacts of the successful builds, as a JSON array:
+
+      [{"name": "dist/app_linux_amd64", "digest": {"sha256": "..."}}]
+
+  The name is the path of the artifact at its final location, relative to
+  the current directory, with forward slashes. The digests of
+  "-checksum-format" are reused. This format is stable; it can be given as
+  is to an attestation signer.
+
+  To resume an interrupted run, give the same "-state-file" again along
+  with "-resume". A build is only reused if it writes to the same output
+  and every artifact it recorded still exists with the same checksum.
+  Artifacts moved with "-artifacts-mode=move" are looked for where they
+  were moved to. Without "-resume" the state file starts over.
+
+Windows Signing:
+
+  With "-winsign", every windows binary is signed after it is built, using
+  osslsigncode (or another signer taking the same arguments, given with
+  "-winsign-cmd"). The signed binary replaces the unsigned one, so any
+  artifacts derived from it, such as checksums, are of the signed binary.
+  Other platforms are not affected. The options are:
+
+    -winsign-pkcs12=""     PKCS#12 file with the certificate and key (required)
+    -winsign-pass-file=""  File containing the password of the PKCS#12 file
+    -winsign-timestamp=""  URL of an RFC 3161 time-stamping server
+    -winsign-cmd="osslsigncode"
+                           Signer to run
+
+  If the signer isn't on the PATH, the windows builds fail.
+
+Packages:
+
+  With "-package", every linux binary is also packaged as a .deb or .rpm, or
+  both with "-package=deb,rpm", installing it to /usr/bin. The packages are
+  written next to the binary, named the way Debian and Fedora name theirs,
+  such as app_1.2.0_arm64.deb and app-1.2.0-1.aarch64.rpm, and are added to
+  the artifacts. Other platforms are not affected. The options are:
+
+    -package-version=""      Version of the packages, the git tag of HEAD by
+                             default. A leading "v" is dropped, and "-" is
+                             turned into "~" so pre-releases sort first
+    -package-maintainer=""   Maintainer, such as "Jane Doe <jane@example.com>"
+    -package-description=""  Description; its first line is the summary
+
+  With "-package=tgz", the binaries of every platform are also put in a
+  tarball of their own, named after the binary, such as
+  app_darwin_arm64.tar.gz, which replaces the binary in the artifacts. Its
+  compression is chosen with:
+
+    -package-compression="gzip"
+                             gzip, zstd or xz, for .tar.gz, .tar.zst or
+                             .tar.xz tarballs
+
+  Files in the packages are dated SOURCE_DATE_EPOCH if it is set, or else
+  the time the binary was built, so packaging the same binary again gives
+  the same packages.
+
+Notifications:
+
+  With "-notify-url", a JSON summary of the run is POSTed to the URL once
+  every build has finished, whether or not they succeeded. The request is
+  retried twice. A failure to notify is reported as a warning and, unless
+  "-strict" is given, doesn't change the exit status. The summary looks
+  like:
+
+    {
+      "go_version": "go1.21.4",
+      "success": false,
+      "builds": [
+        {
+          "platform": "linux/amd64",
+          "package": "github.com/mitchellh/gox",
+          "status": "success",
+          "output": "/src/gox/gox_linux_amd64",
+          "artifacts": ["/src/gox/gox_linux_amd64"],
+          "size": 2315648,
+          "cover": true
+        },
+        {
+          "platform": "windows/arm",
+          "package": "github.com/mitchellh/gox",
+          "status": "failed",
+          "error": "..."
+        }
+      ]
+    }
+
+  The status of a build is "success", "failed", "cached" if it was reused
+  with "-resume", or "skipped" if it wasn't attempted, such as when the
+  host build of "-host-first" fails. Builds stopped by an interrupt are
+  "cancelled", and builds killed by "-build-timeout" are "timed_out". An
+  interrupted run still writes its summary, manifest and state, and a
+  second interrupt exits at once. The "-manifest" flag writes the same
+  summary to a file.
+
+Build Environment:
+
+  By default "go build" inherits the entire environment gox is run with.
+  For hermetic builds, "-env-allowlist" restricts the inherited environment
+  to the listed variables. The variables gox manages itself (GOOS, GOARCH,
+  CGO_ENABLED, CC and CXX) are always set. Note that go build usually needs
+  at least PATH and HOME (or GOCACHE and GOPATH) to work.
+
+Build Cache:
+
+  At high -parallel values, builds can contend on the lock of the shared
+  GOCACHE. The "-worker-gocache" flag gives every worker its own cache in
+  a "worker-N" directory below the given one. This removes the contention
+  at the cost of disk space: each worker cache may grow as large as the
+  shared one would, so expect up to -parallel times the usual cache size.
+  The directories are kept between runs so later builds can reuse them.
+
+  The "-work-dir" flag moves everything the builds write to a temporary
+  directory below the given one: the outputs, GOTMPDIR and, unless
+  "-worker-gocache" is set, GOCACHE. Pointing it at a tmpfs mount speeds up
+  large builds. Only the final outputs are copied to their real location.
+  The temporary directory, including its cache, is removed when gox exits
+  or is interrupted.
+
 Platform Overrides:
 
   The "-gcflags", "-ldflags" and "-asmflags" options can be overridden per-platform
@@ -268,4 +1550,30 @@ Platform Overrides:
     GOX_[OS]_[ARCH]_LDFLAGS
     GOX_[OS]_[ARCH]_ASMFLAGS
 
+  To add gcflags for some platforms only, for example to disable
+  optimizations while debugging a crash on one architecture, use
+  "-gcflags-override" with an os/arch glob:
+
+    -gcflags-override='linux/arm64=all=-N -l'
+
+  It may be given multiple times; the last matching pattern wins. The
+  flags are merged with "-gcflags" (or GOX_[OS]_[ARCH]_GCFLAGS). If both
+  use the same package pattern they are joined, otherwise both are passed
+  to go build, where the override wins for packages matching both.
+
+  The "-pgo" profile can be overridden per-platform in the same way with
+  GOX_[OS]_[ARCH]_PGO, since profiles are often architecture specific.
+
+  Flags for the C compiler and linker of cgo builds are given per-platform
+  with "-cgo-cflags-override" and "-cgo-ldflags-override", using the same
+  os/arch globs:
+
+    -cgo-ldflags-override='linux/*=-lrt'
+    -cgo-ldflags-override='darwin/*=-framework CoreFoundation'
+
+  The last matching pattern wins. The flags are appended to CGO_CFLAGS or
+  CGO_LDFLAGS as inherited from the environment (unless "-env-allowlist"
+  leaves them out), so they come after the inherited flags. Builds without
+  cgo don't get them.
+
 `
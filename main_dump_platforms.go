@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+)
+
+// The "main" method for when -dump-platforms-go is requested. It prints
+// a platform table for the installed Go version in the form of the
+// Platforms_* tables in platform.go, to help keep them up to date.
+func mainDumpPlatforms(version string) int {
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "go%d.%d", &major, &minor); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to determine the Go release from %q\n", version)
+		return 1
+	}
+
+	platforms, err := DistListJSON()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing platforms, -dump-platforms-go requires Go 1.13 or later: %s\n", err)
+		return 1
+	}
+
+	src, err := platformsSource(fmt.Sprintf("%d_%d", major, minor), version, platforms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating platforms: %s\n", err)
+		return 1
+	}
+
+	os.Stdout.Write(src)
+	return 0
+}
+
+// platformsSource returns gofmt-ed Go source declaring the table
+// Platforms_<suffix> with the given platforms. First class ports are the
+// defaults.
+func platformsSource(suffix, version string, platforms []DistPlatform) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Platforms_%s is generated from `go tool dist list -json` of %s.\n",
+		suffix, version)
+	fmt.Fprintf(&buf, "var Platforms_%s = []Platform{\n", suffix)
+	for _, p := range platforms {
+		fmt.Fprintf(&buf, "{%q, %q, %v},\n", p.GOOS, p.GOARCH, p.FirstClass)
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	return format.Source(buf.Bytes())
+}
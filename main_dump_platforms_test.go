@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPlatformsSource(t *testing.T) {
+	src, err := platformsSource("1_21", "go1.21.4", []DistPlatform{
+		{GOOS: "linux", GOARCH: "amd64", CgoSupported: true, FirstClass: true},
+		{GOOS: "wasip1", GOARCH: "wasm"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "// Platforms_1_21 is generated from `go tool dist list -json` of go1.21.4.\n" +
+		"var Platforms_1_21 = []Platform{\n" +
+		"\t{\"linux\", \"amd64\", true},\n" +
+		"\t{\"wasip1\", \"wasm\", false},\n" +
+		"}\n"
+	if string(src) != expected {
+		t.Fatalf("bad:\n%s", src)
+	}
+}
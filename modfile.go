@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+)
+
+// moduleGoVersion returns the minimum Go version required by the go and
+// toolchain directives of the go.work or go.mod that applies to dir,
+// such as "go1.21.5", along with the path of the file. Like the go
+// command, a go.work in dir or any parent wins over the nearest go.mod,
+// unless GOWORK=off. The version is empty if there is no such file or it
+// has no directives.
+func moduleGoVersion(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	names := []string{"go.work", "go.mod"}
+	if os.Getenv("GOWORK") == "off" {
+		names = names[1:]
+	}
+
+	for _, name := range names {
+		path, err := findUp(dir, name)
+		if err != nil {
+			return "", "", err
+		}
+		if path == "" {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", "", err
+		}
+
+		return maxGoVersion(parseGoDirectives(data)), path, nil
+	}
+
+	return "", "", nil
+}
+
+// findUp returns the path of the file name in dir or its closest parent,
+// or an empty string if there is none.
+func findUp(dir, name string) (string, error) {
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// parseGoDirectives returns the versions of the go and toolchain
+// directives of a go.mod or go.work file, as Go versions such as
+// "go1.21". Either is empty if it is missing.
+func parseGoDirectives(data []byte) (string, string) {
+	var goVersion, toolchain string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "go":
+			goVersion = "go" + fields[1]
+		case "toolchain":
+			// "default" and "local" don't name a version
+			if strings.HasPrefix(fields[1], "go1") {
+				toolchain = fields[1]
+			}
+		}
+	}
+
+	return goVersion, toolchain
+}
+
+// maxGoVersion returns the newest of two Go versions, either of which may
+// be empty. A version that can't be parsed loses.
+func maxGoVersion(a, b string) string {
+	va, errA := version.NewVersion(strings.TrimPrefix(a, "go"))
+	vb, errB := version.NewVersion(strings.TrimPrefix(b, "go"))
+	switch {
+	case errB != nil:
+		return a
+	case errA != nil:
+		return b
+	case vb.GreaterThan(va):
+		return b
+	default:
+		return a
+	}
+}
+
+// effectiveGoVersion returns the version of Go that will build in dir,
+// given the version v of the go command that was launched. With the
+// default GOTOOLCHAIN, the go command switches to a newer toolchain if a
+// go.work or go.mod requires one.
+func effectiveGoVersion(v string, dir string, verbose bool) string {
+	// Development versions are assumed to be new enough
+	if os.Getenv("GOTOOLCHAIN") == "local" || !strings.HasPrefix(v, "go") {
+		return v
+	}
+
+	required, path, err := moduleGoVersion(dir)
+	if err != nil {
+		if verbose {
+			fmt.Printf("Unable to read the Go version of the module, using %s: %s\n", v, err)
+		}
+		return v
+	}
+	if required == "" || maxGoVersion(v, required) == v {
+		return v
+	}
+
+	if verbose {
+		fmt.Printf("%s requires %s, using it instead of %s for supported platforms.\n",
+			path, required, v)
+	}
+
+	return required
+}
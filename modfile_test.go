@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGoDirectives(t *testing.T) {
+	cases := []struct {
+		Input     string
+		Go        string
+		Toolchain string
+	}{
+		{"module example.com/foo\n", "", ""},
+		{"module example.com/foo\n\ngo 1.21\n", "go1.21", ""},
+		{"module example.com/foo\n\ngo 1.21 // comment\ntoolchain go1.21.5\n", "go1.21", "go1.21.5"},
+		{"go 1.22\n\nuse ./foo\ntoolchain default\n", "go1.22", ""},
+	}
+
+	for _, tc := range cases {
+		goVersion, toolchain := parseGoDirectives([]byte(tc.Input))
+		if goVersion != tc.Go || toolchain != tc.Toolchain {
+			t.Fatalf("%q: bad: %q %q", tc.Input, goVersion, toolchain)
+		}
+	}
+}
+
+func TestMaxGoVersion(t *testing.T) {
+	cases := []struct {
+		A, B, Expected string
+	}{
+		{"", "", ""},
+		{"go1.21", "", "go1.21"},
+		{"", "go1.21.5", "go1.21.5"},
+		{"go1.21", "go1.21.5", "go1.21.5"},
+		{"go1.22.1", "go1.21.5", "go1.22.1"},
+		{"go1.21", "bogus", "go1.21"},
+	}
+
+	for _, tc := range cases {
+		if actual := maxGoVersion(tc.A, tc.B); actual != tc.Expected {
+			t.Fatalf("%q, %q: bad: %q", tc.A, tc.B, actual)
+		}
+	}
+}
+
+func TestEffectiveGoVersion(t *testing.T) {
+	defer os.Setenv("GOWORK", os.Getenv("GOWORK"))
+	defer os.Setenv("GOTOOLCHAIN", os.Getenv("GOTOOLCHAIN"))
+	os.Unsetenv("GOWORK")
+	os.Unsetenv("GOTOOLCHAIN")
+
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	sub := filepath.Join(td, "mod", "cmd")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// No directives at all
+	if actual := effectiveGoVersion("go1.21.0", sub, false); actual != "go1.21.0" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// The nearest go.mod requires a newer toolchain
+	mod := "module example.com/mod\n\ngo 1.21\ntoolchain go1.22.3\n"
+	if err := ioutil.WriteFile(filepath.Join(td, "mod", "go.mod"), []byte(mod), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := effectiveGoVersion("go1.21.0", sub, false); actual != "go1.22.3" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// The launcher is already new enough
+	if actual := effectiveGoVersion("go1.23.0", sub, false); actual != "go1.23.0" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// A go.work wins over go.mod
+	work := "go 1.24\n\nuse ./mod\n"
+	if err := ioutil.WriteFile(filepath.Join(td, "go.work"), []byte(work), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := effectiveGoVersion("go1.21.0", sub, false); actual != "go1.24" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// Unless workspaces are turned off
+	os.Setenv("GOWORK", "off")
+	if actual := effectiveGoVersion("go1.21.0", sub, false); actual != "go1.22.3" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// GOTOOLCHAIN=local never switches toolchains
+	os.Setenv("GOTOOLCHAIN", "local")
+	if actual := effectiveGoVersion("go1.21.0", sub, false); actual != "go1.21.0" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
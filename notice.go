@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// showNotice reports whether a one-time notice, such as about a change in
+// behavior, should be shown. It is shown once per user: the first call
+// leaves a marker named after the notice in dir, normally the "gox"
+// directory of os.UserCacheDir, and later calls find it. If the marker
+// can't be written, the notice is shown every time rather than never.
+func showNotice(dir, name string) bool {
+	marker := filepath.Join(dir, "notice-"+name)
+	if _, err := os.Stat(marker); err == nil {
+		return false
+	}
+
+	if err := os.MkdirAll(dir, 0755); err == nil {
+		if f, err := os.Create(marker); err == nil {
+			f.Close()
+		}
+	}
+
+	return true
+}
+
+// noticeDir returns the directory of the markers of showNotice, or ""
+// if there is no cache directory.
+func noticeDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "gox")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShowNotice(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "gox")
+	if !showNotice(dir, "test") {
+		t.Fatal("should show the first time")
+	}
+	if showNotice(dir, "test") {
+		t.Fatal("should not show again")
+	}
+	if !showNotice(dir, "other") {
+		t.Fatal("should show another notice")
+	}
+}
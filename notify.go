@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// notifyRetries is how many times a failed notification is retried, and
+// notifyRetryWait how long to wait before each retry.
+var (
+	notifyRetries   = 2
+	notifyRetryWait = time.Second
+)
+
+// notify POSTs the summary as JSON to url. Each attempt is limited to
+// timeout. Any response other than a 2xx status is a failure.
+func notify(url string, timeout time.Duration, s *Summary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: timeout}
+	for attempt := 0; ; attempt++ {
+		err = postJSON(client, url, body)
+		if err == nil || attempt >= notifyRetries {
+			return err
+		}
+
+		time.Sleep(notifyRetryWait)
+	}
+}
+
+func postJSON(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
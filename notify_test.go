@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	defer func(wait time.Duration) { notifyRetryWait = wait }(notifyRetryWait)
+	notifyRetryWait = 0
+
+	// Fail the first attempt to make sure it is retried
+	attempts := 0
+	var received Summary
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("err: %s", err)
+		}
+	}))
+	defer ts.Close()
+
+	summary := NewSummary("go1.21.4", []BuildResult{
+		{Platform: Platform{OS: "windows", Arch: "386"}, Err: errors.New("failed")},
+		{Platform: Platform{OS: "linux", Arch: "amd64"}, Artifacts: []string{"/a"}},
+	})
+	if err := notify(ts.URL, time.Second, summary); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("bad attempts: %d", attempts)
+	}
+	if received.Success || len(received.Builds) != 2 {
+		t.Fatalf("bad: %#v", received)
+	}
+	if received.Builds[0].Platform != "linux/amd64" || received.Builds[1].Error != "failed" {
+		t.Fatalf("bad: %#v", received.Builds)
+	}
+}
+
+func TestNotify_failure(t *testing.T) {
+	defer func(wait time.Duration) { notifyRetryWait = wait }(notifyRetryWait)
+	notifyRetryWait = 0
+
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	if err := notify(ts.URL, time.Second, NewSummary("", nil)); err == nil {
+		t.Fatal("should err")
+	}
+	if attempts != 3 {
+		t.Fatalf("bad attempts: %d", attempts)
+	}
+}
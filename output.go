@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+type OutputTemplateData struct {
+	Dir       string
+	Name      string
+	OS        string
+	OSUname   string
+	Arch      string
+	ArchUname string
+	GoVersion string
+	Commit    string
+	Tag       string
+	Channel   string
+}
+
+// outputTemplateFuncs are the functions available to output templates.
+var outputTemplateFuncs = template.FuncMap{
+	"env": os.Getenv,
+}
+
+// OutputPath renders the output template for these options and returns
+// the absolute path the compiled binary will be written to.
+func (opts *CompileOpts) OutputPath() (string, error) {
+	tplData := OutputTemplateData{
+		Dir:       filepath.Base(opts.PackagePath),
+		Name:      packageName(opts.PackagePath),
+		OS:        opts.Platform.OS,
+		OSUname:   opts.Platform.OSUname(),
+		Arch:      opts.Platform.Arch,
+		ArchUname: opts.Platform.ArchUname(),
+		GoVersion: opts.GoVersion,
+		Commit:    opts.VCS.Commit,
+		Tag:       opts.VCS.Tag,
+		Channel:   opts.Channel,
+	}
+	if tplData.GoVersion == "" {
+		tplData.GoVersion = "unknown"
+	}
+
+	result, err := opts.renderOutput(&tplData)
+	if err != nil {
+		return "", err
+	}
+
+	// An empty channel must not leave "app__linux" behind: render it as
+	// channelSentinel and drop that along with a separator next to it.
+	// Templates that test the channel themselves, such as with
+	// {{if .Channel}}, render differently and are kept as they are.
+	if opts.Channel == "" {
+		tplData.Channel = channelSentinel
+		marked, err := opts.renderOutput(&tplData)
+		if err != nil {
+			return "", err
+		}
+
+		if strings.Replace(marked, channelSentinel, "", -1) == result {
+			result = dropChannelSentinel(marked)
+		}
+		if outputFileName(result) == "" {
+			return "", fmt.Errorf("output template %q names no file without a -channel",
+				opts.OutputTpl)
+		}
+	}
+
+	// Keep race-enabled binaries apart from regular ones
+	if opts.raceEnabled() {
+		result = insertNameSuffix(result, "_race")
+	}
+
+	if opts.OutputName != "" {
+		result = filepath.Join(filepath.Dir(result), opts.OutputName)
+	}
+
+	// Determine the full path to the output so that we can change our
+	// working directory when executing go build.
+	return filepath.Abs(result)
+}
+
+// packageName returns the name of the binary of a package, which is the
+// last element of its path, skipping a major version suffix like "/v2"
+// the way go install does.
+func packageName(pkg string) string {
+	name := path.Base(pkg)
+	if dir := path.Dir(pkg); dir != "." && dir != "/" && isMajorVersion(name) {
+		name = path.Base(dir)
+	}
+
+	return name
+}
+
+// isMajorVersion reports whether s is a major version suffix of a module
+// path, such as "v2".
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' || s[1] < '2' || s[1] > '9' {
+		return false
+	}
+	for _, c := range s[2:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// renderOutput renders the output template and the name suffix, if any,
+// with data.
+func (opts *CompileOpts) renderOutput(data *OutputTemplateData) (string, error) {
+	result, err := renderOutputTemplate("output", opts.OutputTpl, data)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.Platform.OS == "windows" {
+		result += ".exe"
+	}
+
+	if opts.NameSuffix != "" {
+		suffix, err := renderOutputTemplate("suffix", opts.NameSuffix, data)
+		if err != nil {
+			return "", err
+		}
+
+		result = insertNameSuffix(result, suffix)
+	}
+
+	return result, nil
+}
+
+func renderOutputTemplate(name, text string, data *OutputTemplateData) (string, error) {
+	tpl, err := template.New(name).Funcs(outputTemplateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// channelSentinel stands for an empty channel while rendering, so that
+// it can be told apart from the rest of the output.
+const channelSentinel = "\x00channel\x00"
+
+// dropChannelSentinel removes each channelSentinel from path, with the
+// "_" or "-" before it, or else the one after it, if any. Separators that
+// are part of the template or of other fields are left alone.
+func dropChannelSentinel(path string) string {
+	for {
+		i := strings.Index(path, channelSentinel)
+		if i < 0 {
+			return path
+		}
+
+		start, end := i, i+len(channelSentinel)
+		if start > 0 && isNameSeparator(path[start-1]) {
+			start--
+		} else if end < len(path) && isNameSeparator(path[end]) {
+			end++
+		}
+		path = path[:start] + path[end:]
+	}
+}
+
+func isNameSeparator(c byte) bool {
+	return c == '_' || c == '-'
+}
+
+// outputFileName returns the last element of path, without a ".exe" or
+// ".wasm" extension.
+func outputFileName(path string) string {
+	name := path[strings.LastIndexAny(path, "/"+string(filepath.Separator))+1:]
+	if ext := filepath.Ext(name); ext == ".exe" || ext == ".wasm" {
+		name = name[:len(name)-len(ext)]
+	}
+
+	return name
+}
+
+// insertNameSuffix appends suffix to the file name in path, keeping a
+// trailing ".exe" or ".wasm" extension at the end.
+func insertNameSuffix(path, suffix string) string {
+	switch ext := filepath.Ext(path); ext {
+	case ".exe", ".wasm":
+		return path[:len(path)-len(ext)] + suffix + ext
+	default:
+		return path + suffix
+	}
+}
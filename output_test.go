@@ -0,0 +1,253 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileOptsOutputPath_goVersion(t *testing.T) {
+	opts := &CompileOpts{
+		PackagePath: "foo",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		OutputTpl:   "dist/{{.GoVersion}}/{{.Dir}}_{{.OS}}_{{.Arch}}",
+		GoVersion:   "go1.21.4",
+	}
+
+	path, err := opts.OutputPath()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasSuffix(path, filepath.Join("dist", "go1.21.4", "foo_linux_amd64")) {
+		t.Fatalf("bad: %s", path)
+	}
+
+	opts.GoVersion = ""
+	path, err = opts.OutputPath()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasSuffix(path, filepath.Join("dist", "unknown", "foo_linux_amd64")) {
+		t.Fatalf("bad: %s", path)
+	}
+}
+
+func TestCompileOptsOutputPath_nameSuffix(t *testing.T) {
+	os.Setenv("GOX_TEST_BUILD_DATE", "20240115")
+	defer os.Unsetenv("GOX_TEST_BUILD_DATE")
+
+	cases := []struct {
+		Platform Platform
+		Tpl      string
+		Expected string
+	}{
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"{{.Dir}}_{{.OS}}_{{.Arch}}",
+			"foo_linux_amd64_nightly-20240115",
+		},
+		{
+			Platform{OS: "windows", Arch: "amd64"},
+			"{{.Dir}}_{{.OS}}_{{.Arch}}",
+			"foo_windows_amd64_nightly-20240115.exe",
+		},
+		{
+			Platform{OS: "js", Arch: "wasm"},
+			"{{.Dir}}_{{.OS}}_{{.Arch}}.wasm",
+			"foo_js_wasm_nightly-20240115.wasm",
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &CompileOpts{
+			PackagePath: "foo",
+			Platform:    tc.Platform,
+			OutputTpl:   tc.Tpl,
+			NameSuffix:  `_nightly-{{env "GOX_TEST_BUILD_DATE"}}`,
+		}
+
+		path, err := opts.OutputPath()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if filepath.Base(path) != tc.Expected {
+			t.Fatalf("bad: %s", path)
+		}
+	}
+}
+
+func TestCompileOptsOutputPath_channel(t *testing.T) {
+	cases := []struct {
+		Platform Platform
+		Tpl      string
+		Channel  string
+		Expected string
+	}{
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"{{.Dir}}_{{.Channel}}_{{.OS}}_{{.Arch}}",
+			"beta",
+			"foo_beta_linux_amd64",
+		},
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"{{.Dir}}_{{.Channel}}_{{.OS}}_{{.Arch}}",
+			"",
+			"foo_linux_amd64",
+		},
+		{
+			Platform{OS: "windows", Arch: "amd64"},
+			"{{.Dir}}_{{.OS}}_{{.Arch}}-{{.Channel}}",
+			"",
+			"foo_windows_amd64.exe",
+		},
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"dist/{{.Channel}}/{{.Dir}}_{{.OS}}",
+			"",
+			filepath.Join("dist", "foo_linux"),
+		},
+		{
+			// Doubled separators are only dropped for an empty channel
+			Platform{OS: "linux", Arch: "amd64"},
+			"{{.Dir}}__{{.OS}}",
+			"",
+			"foo__linux",
+		},
+		{
+			// Only the separator next to the channel is dropped
+			Platform{OS: "linux", Arch: "amd64"},
+			"{{.Dir}}__{{.Channel}}_{{.OS}}",
+			"",
+			"foo__linux",
+		},
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"{{.Channel}}-{{.Dir}}_{{.Tag}}",
+			"",
+			"foo_v1--rc",
+		},
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"srv/_builds/{{.Dir}}_{{.Channel}}",
+			"",
+			filepath.Join("srv", "_builds", "foo"),
+		},
+		{
+			Platform{OS: "linux", Arch: "amd64"},
+			"{{.Dir}}{{if .Channel}}-{{.Channel}}{{else}}-stable{{end}}",
+			"",
+			"foo-stable",
+		},
+	}
+
+	for _, tc := range cases {
+		opts := &CompileOpts{
+			PackagePath: "foo",
+			Platform:    tc.Platform,
+			OutputTpl:   tc.Tpl,
+			Channel:     tc.Channel,
+			VCS:         VCSInfo{Tag: "v1--rc"},
+		}
+
+		path, err := opts.OutputPath()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !strings.HasSuffix(path, string(filepath.Separator)+tc.Expected) {
+			t.Fatalf("%s: bad: %s", tc.Tpl, path)
+		}
+	}
+
+	// Other fields keep their separators too
+	opts := &CompileOpts{
+		PackagePath: "example.com/my__tool",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		OutputTpl:   "{{.Dir}}_{{.Channel}}",
+	}
+	path, err := opts.OutputPath()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filepath.Base(path) != "my__tool" {
+		t.Fatalf("bad: %s", path)
+	}
+
+	// Nothing is left to name the binary
+	for _, tpl := range []string{"{{.Channel}}", "dist/{{.Channel}}_"} {
+		opts := &CompileOpts{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "windows", Arch: "amd64"},
+			OutputTpl:   tpl,
+		}
+		if _, err := opts.OutputPath(); err == nil {
+			t.Fatalf("%s: should error", tpl)
+		}
+	}
+}
+
+func TestInsertNameSuffix(t *testing.T) {
+	cases := map[string]string{
+		"app":         "app-x",
+		"app.exe":     "app-x.exe",
+		"app.wasm":    "app-x.wasm",
+		"dist.v1/app": "dist.v1/app-x",
+		"app.tar":     "app.tar-x",
+	}
+
+	for path, expected := range cases {
+		if actual := insertNameSuffix(path, "-x"); actual != expected {
+			t.Fatalf("%s: bad: %s", path, actual)
+		}
+	}
+}
+
+func TestCompileOptsOutputPath_race(t *testing.T) {
+	cases := []struct {
+		Platform  Platform
+		GoVersion string
+		Expected  string
+	}{
+		{Platform{OS: "linux", Arch: "amd64"}, "go1.21.4", "foo_linux_amd64_race"},
+		{Platform{OS: "windows", Arch: "amd64"}, "go1.21.4", "foo_windows_amd64_race.exe"},
+		{Platform{OS: "linux", Arch: "arm"}, "go1.21.4", "foo_linux_arm"},
+		{Platform{OS: "linux", Arch: "arm64"}, "go1.11.13", "foo_linux_arm64"},
+		{Platform{OS: "linux", Arch: "arm64"}, "go1.12", "foo_linux_arm64_race"},
+	}
+
+	for _, tc := range cases {
+		opts := &CompileOpts{
+			PackagePath: "foo",
+			Platform:    tc.Platform,
+			OutputTpl:   "{{.Dir}}_{{.OS}}_{{.Arch}}",
+			Race:        true,
+			GoVersion:   tc.GoVersion,
+		}
+
+		path, err := opts.OutputPath()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if filepath.Base(path) != tc.Expected {
+			t.Fatalf("bad: %s", path)
+		}
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	cases := map[string]string{
+		"example.com/foo":         "foo",
+		"example.com/foo/cmd/ctl": "ctl",
+		"example.com/foo/v2":      "foo",
+		"example.com/foo/v1":      "v1",
+		"example.com/foo/v2x":     "v2x",
+		"v2":                      "v2",
+	}
+
+	for pkg, expected := range cases {
+		if actual := packageName(pkg); actual != expected {
+			t.Fatalf("%s: expected %q, got %q", pkg, expected, actual)
+		}
+	}
+}
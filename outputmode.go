@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseOutputMode parses the value of -output-mode, an octal permission
+// such as "0755" or "755". A mode of 0 is refused, as nothing could use
+// the binary.
+func parseOutputMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("-output-mode %q must be an octal mode such as 0755", s)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// outputModeStep returns a post-build step that sets the permissions of
+// the binary to mode, whatever the umask it was written with.
+func outputModeStep(mode os.FileMode) postBuildStep {
+	return func(opts *CompileOpts, result *BuildResult) error {
+		return os.Chmod(result.Output, mode)
+	}
+}
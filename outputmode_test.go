@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseOutputMode(t *testing.T) {
+	cases := map[string]os.FileMode{
+		"0755":  0755,
+		"750":   0750,
+		"0o644": 0644,
+	}
+	for input, expected := range cases {
+		actual, err := parseOutputMode(input)
+		if err != nil {
+			t.Fatalf("%s: err: %s", input, err)
+		}
+		if actual != expected {
+			t.Fatalf("%s: bad: %o", input, actual)
+		}
+	}
+
+	for _, input := range []string{"", "0000", "rwxr-xr-x", "0789", "01755", "-1"} {
+		if _, err := parseOutputMode(input); err == nil {
+			t.Fatalf("%s: should error", input)
+		}
+	}
+}
+
+func TestOutputModeStep(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permissions on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "app")
+	if err := ioutil.WriteFile(output, []byte("binary"), 0700); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result := &BuildResult{Output: output}
+	if err := outputModeStep(0755)(&CompileOpts{}, result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("bad: %o", info.Mode().Perm())
+	}
+}
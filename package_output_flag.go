@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PackageOutputFlag is a flag.Value that maps packages to their own
+// output templates, for example "./cmd/ctl=dist/ctl_{{.OS}}_{{.Arch}}".
+// The flag may be given multiple times. If more than one package matches,
+// the one given last wins.
+type PackageOutputFlag struct {
+	packages  []string
+	templates []string
+}
+
+// Set parses a single "package=template" pair. The package is either an
+// import path or a path relative to the current directory, such as
+// "./cmd/ctl", which matches any import path ending in "/cmd/ctl".
+func (f *PackageOutputFlag) Set(s string) error {
+	idx := strings.Index(s, "=")
+	if idx <= 0 || idx == len(s)-1 {
+		return fmt.Errorf(
+			"invalid format: %s should be package=template", s)
+	}
+
+	pkg := strings.TrimSuffix(s[:idx], "/")
+	f.packages = append(f.packages, pkg)
+	f.templates = append(f.templates, s[idx+1:])
+	return nil
+}
+
+func (f *PackageOutputFlag) String() string {
+	pairs := make([]string, len(f.packages))
+	for i, p := range f.packages {
+		pairs[i] = p + "=" + f.templates[i]
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// Lookup returns the output template of the last entry matching the
+// import path of a package.
+func (f *PackageOutputFlag) Lookup(importPath string) (string, bool) {
+	for i := len(f.packages) - 1; i >= 0; i-- {
+		pkg := f.packages[i]
+		if strings.HasPrefix(pkg, "./") {
+			rel := strings.TrimPrefix(pkg, "./")
+			if strings.HasSuffix(importPath, "/"+rel) {
+				return f.templates[i], true
+			}
+			continue
+		}
+
+		if pkg == importPath {
+			return f.templates[i], true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPackageOutputFlag(t *testing.T) {
+	var f PackageOutputFlag
+	for _, s := range []string{
+		"./cmd/ctl=dist/ctl_{{.OS}}",
+		"example.com/foo/cmd/food=dist/d_{{.OS}}",
+		"./cmd/ctl/=dist/{{.Name}}",
+	} {
+		if err := f.Set(s); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	cases := []struct {
+		Package  string
+		Expected string
+		Found    bool
+	}{
+		{"example.com/foo/cmd/ctl", "dist/{{.Name}}", true},
+		{"example.com/foo/cmd/food", "dist/d_{{.OS}}", true},
+		{"example.com/foo/cmd/other", "", false},
+		{"example.com/foo/xcmd/ctl2", "", false},
+	}
+
+	for _, tc := range cases {
+		actual, ok := f.Lookup(tc.Package)
+		if actual != tc.Expected || ok != tc.Found {
+			t.Fatalf("%s: bad: %q %v", tc.Package, actual, ok)
+		}
+	}
+}
+
+func TestPackageOutputFlag_invalid(t *testing.T) {
+	var f PackageOutputFlag
+	for _, s := range []string{"", "foo", "=tpl", "foo="} {
+		if err := f.Set(s); err == nil {
+			t.Fatalf("%q: should error", s)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// checkOutputCollisions verifies that no two builds resolve to the same
+// output path, which would make them silently overwrite each other.
+func checkOutputCollisions(jobs []*CompileOpts) error {
+	byPath := make(map[string][]string)
+	for _, opts := range jobs {
+		path, err := opts.OutputPath()
+		if err != nil {
+			return fmt.Errorf("%s: %s", opts.Platform.String(), err)
+		}
+
+		byPath[path] = append(byPath[path], fmt.Sprintf(
+			"%s (%s)", opts.Platform.String(), opts.PackagePath))
+	}
+
+	var collisions []string
+	for path, platforms := range byPath {
+		if len(platforms) > 1 {
+			collisions = append(collisions, fmt.Sprintf(
+				"%s is the output of: %s", path, strings.Join(platforms, ", ")))
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	sort.Strings(collisions)
+	return fmt.Errorf("multiple builds share an output path:\n  %s",
+		strings.Join(collisions, "\n  "))
+}
+
+// checkOutputDirConflicts verifies that no build's output is a directory
+// in the output path of another build, such as dist/linux for one and
+// dist/linux/amd64 for another. Whichever builds first makes the other
+// fail, or with -work-dir, clobbers its output.
+func checkOutputDirConflicts(jobs []*CompileOpts) error {
+	byPath := make(map[string]string)
+	paths := make([]string, 0, len(jobs))
+	for _, opts := range jobs {
+		path, err := opts.OutputPath()
+		if err != nil {
+			return fmt.Errorf("%s: %s", opts.Platform.String(), err)
+		}
+
+		byPath[path] = fmt.Sprintf("%s (%s)", opts.Platform.String(), opts.PackagePath)
+		paths = append(paths, path)
+	}
+
+	var conflicts []string
+	for _, path := range paths {
+		for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if file, ok := byPath[dir]; ok {
+				conflicts = append(conflicts, fmt.Sprintf(
+					"%s is the output of %s, but %s needs it as a directory for %s",
+					dir, file, byPath[path], path))
+			}
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	sort.Strings(conflicts)
+	return fmt.Errorf("outputs of some builds are directories of others:\n  %s",
+		strings.Join(conflicts, "\n  "))
+}
+
+// checkOutputRoot verifies that the output of every build is inside the
+// directory root, so that templates or config can't write anywhere else
+// with "../" or absolute paths. Paths are compared after resolving
+// symlinks, for as much of them as already exists.
+func checkOutputRoot(root string, jobs []*CompileOpts) error {
+	root, err := resolvePath(root)
+	if err != nil {
+		return err
+	}
+
+	var escaped []string
+	for _, opts := range jobs {
+		path, err := opts.OutputPath()
+		if err != nil {
+			return fmt.Errorf("%s: %s", opts.Platform.String(), err)
+		}
+
+		resolved, err := resolvePath(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, resolved)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			escaped = append(escaped, fmt.Sprintf(
+				"%s (%s): %s", opts.Platform.String(), opts.PackagePath, path))
+		}
+	}
+	if len(escaped) == 0 {
+		return nil
+	}
+
+	sort.Strings(escaped)
+	return fmt.Errorf("outputs outside of -output-root %s:\n  %s",
+		root, strings.Join(escaped, "\n  "))
+}
+
+// resolvePath returns the absolute, clean form of path with symlinks
+// resolved. Only the part of the path that exists is resolved; the rest
+// is appended as is.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// checkPgoProfiles verifies that every PGO profile exists before anything
+// is built, and makes the paths absolute since go build may run in a
+// different working directory. The special values "auto" and "off" are
+// passed through to go build unchanged.
+func checkPgoProfiles(jobs []*CompileOpts) error {
+	for _, opts := range jobs {
+		if opts.Pgo == "" || opts.Pgo == "auto" || opts.Pgo == "off" {
+			continue
+		}
+
+		path, err := filepath.Abs(opts.Pgo)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%s: PGO profile %s", opts.Platform.String(), err)
+		}
+
+		opts.Pgo = path
+	}
+
+	return nil
+}
+
+// checkOutputTemplates returns a warning for each output template that
+// is used for more than one OS but doesn't reference the OS. Such builds
+// only get distinct outputs by accident, for example if the windows
+// ".exe" extension sets them apart, so it is most likely a mistake even
+// when checkOutputCollisions finds nothing. Builds with an output name
+// override are skipped.
+func checkOutputTemplates(jobs []*CompileOpts) []string {
+	osesByTemplate := make(map[string]map[string]bool)
+	var templates []string
+	for _, opts := range jobs {
+		if opts.OutputName != "" {
+			continue
+		}
+
+		key := opts.OutputTpl + "\x00" + opts.NameSuffix
+		if osesByTemplate[key] == nil {
+			osesByTemplate[key] = make(map[string]bool)
+			templates = append(templates, key)
+		}
+		osesByTemplate[key][opts.Platform.OS] = true
+	}
+
+	var warnings []string
+	for _, key := range templates {
+		if len(osesByTemplate[key]) < 2 {
+			continue
+		}
+
+		parts := strings.SplitN(key, "\x00", 2)
+		fields := make(map[string]bool)
+		for i, text := range parts {
+			// Templates that don't parse fail the build with a better
+			// error than we could give here.
+			if err := templateFields(fmt.Sprintf("t%d", i), text, fields); err != nil {
+				fields = nil
+				break
+			}
+		}
+		if fields == nil || fields["OS"] || fields["OSUname"] {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf(
+			"output template %q is used for several operating systems but "+
+				"references neither {{.OS}} nor {{.OSUname}}, so their outputs may "+
+				"overwrite each other", parts[0]))
+	}
+
+	return warnings
+}
+
+// templateFields adds the names of the fields of the template data that
+// the template text references to fields.
+func templateFields(name, text string, fields map[string]bool) error {
+	trees, err := parse.Parse(name, text, "", "", outputTemplateFuncs, builtinTemplateFuncs)
+	if err != nil {
+		return err
+	}
+
+	for _, tree := range trees {
+		if tree.Root != nil {
+			walkTemplateFields(tree.Root, fields)
+		}
+	}
+
+	return nil
+}
+
+// builtinTemplateFuncs declares the builtin functions of text/template to
+// the parser, which only needs their names.
+var builtinTemplateFuncs = map[string]interface{}{
+	"and": nil, "call": nil, "html": nil, "index": nil, "js": nil,
+	"len": nil, "not": nil, "or": nil, "print": nil, "printf": nil,
+	"println": nil, "urlquery": nil, "slice": nil,
+	"eq": nil, "ge": nil, "gt": nil, "le": nil, "lt": nil, "ne": nil,
+}
+
+func walkTemplateFields(node parse.Node, fields map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplateFields(child, fields)
+		}
+	case *parse.ActionNode:
+		walkTemplateFields(n.Pipe, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkTemplateFields(cmd, fields)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplateFields(arg, fields)
+		}
+	case *parse.FieldNode:
+		fields[n.Ident[0]] = true
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			fields[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		walkTemplateFields(n.Node, fields)
+	case *parse.IfNode:
+		walkTemplateFields(n.Pipe, fields)
+		walkTemplateFields(n.List, fields)
+		walkTemplateFields(n.ElseList, fields)
+	case *parse.RangeNode:
+		walkTemplateFields(n.Pipe, fields)
+		walkTemplateFields(n.List, fields)
+		walkTemplateFields(n.ElseList, fields)
+	case *parse.WithNode:
+		walkTemplateFields(n.Pipe, fields)
+		walkTemplateFields(n.List, fields)
+		walkTemplateFields(n.ElseList, fields)
+	case *parse.TemplateNode:
+		walkTemplateFields(n.Pipe, fields)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckOutputCollisions(t *testing.T) {
+	jobs := []*CompileOpts{
+		{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "linux", Arch: "amd64"},
+			OutputTpl:   "{{.Dir}}_{{.OS}}_{{.Arch}}",
+		},
+		{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "windows", Arch: "amd64"},
+			OutputTpl:   "{{.Dir}}_{{.OS}}_{{.Arch}}",
+		},
+	}
+	if err := checkOutputCollisions(jobs); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// An override that makes two outputs the same must be caught
+	jobs[0].OutputName = "app"
+	jobs[1].OutputName = "app"
+	if err := checkOutputCollisions(jobs); err == nil {
+		t.Fatal("should err")
+	}
+}
+
+func TestCheckOutputDirConflicts(t *testing.T) {
+	job := func(os, arch, tpl string) *CompileOpts {
+		return &CompileOpts{
+			PackagePath: "foo",
+			Platform:    Platform{OS: os, Arch: arch},
+			OutputTpl:   tpl,
+		}
+	}
+
+	jobs := []*CompileOpts{
+		job("linux", "amd64", "dist/{{.OS}}/{{.Arch}}"),
+		job("darwin", "amd64", "dist/{{.OS}}/{{.Arch}}"),
+	}
+	if err := checkOutputDirConflicts(jobs); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A partly parameterized template for one platform
+	jobs = append(jobs, job("linux", "arm64", "dist/{{.OS}}"))
+	err := checkOutputDirConflicts(jobs)
+	if err == nil {
+		t.Fatal("should err")
+	}
+	if !strings.Contains(err.Error(), "linux/arm64") || !strings.Contains(err.Error(), "linux/amd64") {
+		t.Fatalf("bad: %s", err)
+	}
+	if strings.Contains(err.Error(), "darwin") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestCheckOutputTemplates(t *testing.T) {
+	job := func(os, tpl string) *CompileOpts {
+		return &CompileOpts{
+			PackagePath: "foo",
+			Platform:    Platform{OS: os, Arch: "amd64"},
+			OutputTpl:   tpl,
+		}
+	}
+
+	cases := []struct {
+		Jobs     []*CompileOpts
+		Warnings int
+	}{
+		{
+			[]*CompileOpts{
+				job("linux", "{{.Dir}}_{{.OS}}_{{.Arch}}"),
+				job("windows", "{{.Dir}}_{{.OS}}_{{.Arch}}"),
+			},
+			0,
+		},
+		{
+			[]*CompileOpts{
+				job("linux", "{{.Dir}}_{{.Arch}}"),
+				job("windows", "{{.Dir}}_{{.Arch}}"),
+			},
+			1,
+		},
+		{
+			// A single OS is fine
+			[]*CompileOpts{
+				job("linux", "{{.Dir}}_{{.Arch}}"),
+			},
+			0,
+		},
+		{
+			// The OS may be used anywhere in the template
+			[]*CompileOpts{
+				job("linux", `{{if eq .OSUname "Linux"}}lin{{end}}_{{.Arch}}`),
+				job("darwin", `{{if eq .OSUname "Linux"}}lin{{end}}_{{.Arch}}`),
+			},
+			0,
+		},
+		{
+			[]*CompileOpts{
+				job("linux", "{{with .Arch}}{{$.OS}}_{{.}}{{end}}"),
+				job("darwin", "{{with .Arch}}{{$.OS}}_{{.}}{{end}}"),
+			},
+			0,
+		},
+		{
+			// Or in the name suffix
+			[]*CompileOpts{
+				{PackagePath: "foo", Platform: Platform{OS: "linux", Arch: "amd64"}, OutputTpl: "{{.Dir}}", NameSuffix: "_{{.OS}}"},
+				{PackagePath: "foo", Platform: Platform{OS: "darwin", Arch: "amd64"}, OutputTpl: "{{.Dir}}", NameSuffix: "_{{.OS}}"},
+			},
+			0,
+		},
+		{
+			// Invalid templates are left to the build
+			[]*CompileOpts{
+				job("linux", "{{.Dir"),
+				job("windows", "{{.Dir"),
+			},
+			0,
+		},
+	}
+
+	for i, tc := range cases {
+		warnings := checkOutputTemplates(tc.Jobs)
+		if len(warnings) != tc.Warnings {
+			t.Fatalf("%d: bad: %#v", i, warnings)
+		}
+	}
+}
+
+func TestCheckOutputRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	outside, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(outside)
+
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks not supported: %s", err)
+	}
+
+	job := func(tpl string) *CompileOpts {
+		return &CompileOpts{
+			PackagePath: "foo",
+			Platform:    Platform{OS: "linux", Arch: "amd64"},
+			OutputTpl:   tpl,
+		}
+	}
+
+	cases := []struct {
+		Tpl string
+		Err bool
+	}{
+		{filepath.Join(root, "dist", "{{.OS}}_{{.Arch}}"), false},
+		{filepath.Join(root, "dist", "..", "{{.OS}}"), false},
+		{filepath.Join(root, "..", "{{.OS}}"), true},
+		{filepath.Join(outside, "{{.OS}}"), true},
+		{filepath.Join(root, "link", "{{.OS}}"), true},
+	}
+	for _, tc := range cases {
+		err := checkOutputRoot(root, []*CompileOpts{job(tc.Tpl)})
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Tpl, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
 	version "github.com/hashicorp/go-version"
@@ -13,18 +14,246 @@ type Platform struct {
 	OS   string
 	Arch string
 
-	// Default, if true, will be included as a default build target
-	// if no OS/arch is specified. We try to only set as a default popular
-	// targets or targets that are generally useful. For example, Android
-	// is not a default because it is quite rare that you're cross-compiling
-	// something to Android AND something like Linux.
+	// Default is true if the platform is a first class port of the
+	// version of Go the platform comes from, as defined by the Go porting
+	// policy. Default platforms are the ones built if no OS/arch is
+	// specified.
 	Default bool
 }
 
+// Clone returns a copy of the platform. Platform has value semantics so
+// this is the same as an assignment, but it makes the intent explicit
+// when building up a list of platforms from an existing one.
+func (p Platform) Clone() Platform {
+	return p
+}
+
 func (p *Platform) String() string {
 	return fmt.Sprintf("%s/%s", p.OS, p.Arch)
 }
 
+// Validate checks that the platform is a real GOOS/GOARCH pair, supported
+// by any version of Go, as opposed to just the one in use. It returns an
+// error describing the first problem found.
+func (p Platform) Validate() error {
+	switch {
+	case p.OS == "":
+		return fmt.Errorf("GOOS is empty")
+	case p.Arch == "":
+		return fmt.Errorf("GOARCH is empty")
+	case p.OS != strings.ToLower(p.OS):
+		return fmt.Errorf("GOOS %q must be lowercase", p.OS)
+	case p.Arch != strings.ToLower(p.Arch):
+		return fmt.Errorf("GOARCH %q must be lowercase", p.Arch)
+	case !knownOS[p.OS]:
+		return fmt.Errorf("unknown GOOS %q", p.OS)
+	case !knownArch[p.Arch]:
+		return fmt.Errorf("unknown GOARCH %q", p.Arch)
+	}
+
+	if !knownPlatform(p.String()) {
+		return fmt.Errorf("unknown platform %q", p.String())
+	}
+
+	return nil
+}
+
+// knownPlatform reports whether the os/arch pair is in any of the platform
+// tables, or is reported by `go tool dist list` for the Go on the PATH.
+func knownPlatform(osarch string) bool {
+	tables := [][]Platform{
+		Platforms_1_0, Platforms_1_1, Platforms_1_3, Platforms_1_4,
+		Platforms_1_5, Platforms_1_6, Platforms_1_7, Platforms_1_8,
+		Platforms_1_9, Platforms_1_10, Platforms_1_11, Platforms_1_12,
+		PlatformsLatest,
+	}
+	for _, table := range tables {
+		for _, p := range table {
+			if p.String() == osarch {
+				return true
+			}
+		}
+	}
+
+	return distAllPorts()[osarch]
+}
+
+// FirstClass reports whether the platform is a first class port of Go,
+// according to `go tool dist list -json` of the `go` binary on the PATH.
+// If that isn't available, such as with Go older than 1.13, the Default
+// flag of the platform is used instead.
+func (p *Platform) FirstClass() bool {
+	if ports := distFirstClassPorts(); ports != nil {
+		return ports[p.String()]
+	}
+
+	return p.Default
+}
+
+// Experimental reports whether the platform is an experimental port in
+// Go version goVersion, such as "go1.21.4", as announced in the release
+// notes of the version that added it. Versions that can't be parsed,
+// such as development versions, are assumed to be newer than the table.
+func (p *Platform) Experimental(goVersion string) bool {
+	ports, ok := experimentalPorts[p.String()]
+	if !ok {
+		return false
+	}
+
+	current, err := version.NewVersion(strings.TrimPrefix(goVersion, "go"))
+	if err != nil {
+		return ports.until == ""
+	}
+
+	// Only major.minor matters, and pre-releases count as their release
+	segments := current.Segments()
+	current = version.Must(version.NewVersion(fmt.Sprintf("%d.%d", segments[0], segments[1])))
+	if current.LessThan(version.Must(version.NewVersion(ports.since))) {
+		return false
+	}
+
+	return ports.until == "" || current.LessThan(version.Must(version.NewVersion(ports.until)))
+}
+
+// experimentalPorts are the ports the Go release notes introduced as
+// experimental: since the version that added them, up to but excluding
+// the version they stopped being experimental in, if any. Add new
+// experimental ports here as Go ships them.
+var experimentalPorts = map[string]struct{ since, until string }{
+	"js/wasm":         {"1.11", "1.13"}, // Go 1.11 release notes, "WebAssembly"
+	"linux/riscv64":   {"1.14", "1.16"}, // Go 1.14 release notes, "RISC-V"
+	"linux/loong64":   {"1.19", "1.20"}, // Go 1.19 release notes, "LoongArch 64-bit"
+	"freebsd/riscv64": {"1.20", ""},     // Go 1.20 release notes, "FreeBSD/RISC-V"
+	"openbsd/riscv64": {"1.23", ""},     // Go 1.23 release notes, "OpenBSD"
+	"wasip1/wasm":     {"1.21", ""},     // Go 1.21 release notes, "WebAssembly System Interface"
+	"openbsd/ppc64":   {"1.22", ""},     // Go 1.22 release notes, "OpenBSD"
+}
+
+// SupportsRace reports whether the race detector (go build -race) is
+// supported when building for this platform with Go version goVersion,
+// such as "go1.21.4". Versions without a "go" prefix, such as development
+// builds, are assumed to be new enough.
+func (p *Platform) SupportsRace(goVersion string) bool {
+	since, ok := racePlatforms[p.String()]
+	if !ok {
+		return false
+	}
+
+	ok, err := GoVersionAtLeast(goVersion, since)
+	return err == nil && ok
+}
+
+// racePlatforms are the platforms the race detector supports, by the
+// version of Go that added support for them. This mirrors
+// RaceDetectorSupported in Go's internal/platform package and should be
+// kept in sync with it as Go adds (or drops) support.
+var racePlatforms = map[string]string{
+	"darwin/amd64":  "1.1",
+	"darwin/arm64":  "1.16",
+	"freebsd/amd64": "1.3",
+	"linux/amd64":   "1.1",
+	"linux/arm64":   "1.12", // Go 1.12 release notes, "Race detector"
+	"linux/loong64": "1.24",
+	"linux/ppc64le": "1.11", // Go 1.11 release notes, "Race detector"
+	"linux/riscv64": "1.26",
+	"linux/s390x":   "1.19",
+	"netbsd/amd64":  "1.11", // Go 1.11 release notes, "Race detector"
+	"windows/amd64": "1.1",
+}
+
+// DockerPlatform returns the platform in the os/arch[/variant] form used
+// by Docker and OCI images, such as "linux/arm/v7" or "linux/arm64/v8".
+// It is empty for platforms that containers don't run on. The variant of
+// arm is taken from GOARM, which defaults to 7 like it does in Go.
+func (p *Platform) DockerPlatform() string {
+	if p.OS != "linux" && p.OS != "windows" {
+		return ""
+	}
+
+	result := p.OS + "/" + p.Arch
+	switch p.Arch {
+	case "arm":
+		result += "/v" + goarm()
+	case "arm64":
+		result += "/v8"
+	}
+
+	return result
+}
+
+// goarm returns the ARM version binaries are built for, from GOARM, which
+// defaults to 7 like it does in Go.
+func goarm() string {
+	v := os.Getenv("GOARM")
+	// Newer versions of Go allow GOARM=7,softfloat
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		v = "7"
+	}
+
+	return v
+}
+
+// DebianArch returns the architecture name Debian and its derivatives use
+// for the platform's architecture, such as "armhf" for arm or "ppc64el"
+// for ppc64le. It is empty for architectures Debian has no name for. The
+// name of arm depends on GOARM: "armel" before ARMv7, and "armhf" after.
+func (p *Platform) DebianArch() string {
+	if p.Arch == "arm" && goarm() < "7" {
+		return "armel"
+	}
+
+	return map[string]string{
+		"386":      "i386",
+		"amd64":    "amd64",
+		"arm":      "armhf",
+		"arm64":    "arm64",
+		"loong64":  "loong64",
+		"mips":     "mips",
+		"mipsle":   "mipsel",
+		"mips64":   "mips64",
+		"mips64le": "mips64el",
+		"ppc64":    "ppc64",
+		"ppc64le":  "ppc64el",
+		"riscv64":  "riscv64",
+		"s390x":    "s390x",
+	}[p.Arch]
+}
+
+// RPMArch returns the architecture name RPM uses for the platform's
+// architecture, such as "x86_64" for amd64 or "aarch64" for arm64. It is
+// empty for architectures RPM has no name for. The name of arm depends on
+// GOARM, such as "armv7hl" for ARMv7 and "armv6hl" for ARMv6.
+func (p *Platform) RPMArch() string {
+	if p.Arch == "arm" {
+		switch goarm() {
+		case "5":
+			return "armv5tel"
+		case "6":
+			return "armv6hl"
+		default:
+			return "armv7hl"
+		}
+	}
+
+	return map[string]string{
+		"386":      "i686",
+		"amd64":    "x86_64",
+		"arm64":    "aarch64",
+		"loong64":  "loongarch64",
+		"mips":     "mips",
+		"mipsle":   "mipsel",
+		"mips64":   "mips64",
+		"mips64le": "mips64el",
+		"ppc64":    "ppc64",
+		"ppc64le":  "ppc64le",
+		"riscv64":  "riscv64",
+		"s390x":    "s390x",
+	}[p.Arch]
+}
+
 /// Like `uname -s`
 // Matches https://github.com/golang/go/blob/master/src/go/build/syslist.go
 func (p *Platform) OSUname() string {
@@ -70,6 +299,11 @@ func (p *Platform) ArchUname() string {
 	}[p.Arch]
 }
 
+// The platform tables below list the platforms of each version of Go.
+// The Default flag of a platform is true exactly when it is a first class
+// port of that version, as defined by https://go.dev/wiki/PortingPolicy:
+// darwin, linux and windows on 386 and amd64, linux/arm, and from Go 1.8
+// on linux/arm64. TestPlatformIsFirstClassDefault checks this.
 var (
 	Platforms_1_0 = []Platform{
 		{"darwin", "386", true},
@@ -77,102 +311,260 @@ var (
 		{"linux", "386", true},
 		{"linux", "amd64", true},
 		{"linux", "arm", true},
-		{"freebsd", "386", true},
-		{"freebsd", "amd64", true},
-		{"openbsd", "386", true},
-		{"openbsd", "amd64", true},
+		{"freebsd", "386", false},
+		{"freebsd", "amd64", false},
+		{"openbsd", "386", false},
+		{"openbsd", "amd64", false},
 		{"windows", "386", true},
 		{"windows", "amd64", true},
 	}
 
-	Platforms_1_1 = append(Platforms_1_0, []Platform{
-		{"freebsd", "arm", true},
-		{"netbsd", "386", true},
-		{"netbsd", "amd64", true},
-		{"netbsd", "arm", true},
-		{"plan9", "386", false},
-	}...)
-
-	Platforms_1_3 = append(Platforms_1_1, []Platform{
-		{"dragonfly", "386", false},
-		{"dragonfly", "amd64", false},
-		{"nacl", "amd64", false},
-		{"nacl", "amd64p32", false},
-		{"nacl", "arm", false},
-		{"solaris", "amd64", false},
-	}...)
-
-	Platforms_1_4 = append(Platforms_1_3, []Platform{
-		{"android", "arm", false},
-		{"plan9", "amd64", false},
-	}...)
-
-	Platforms_1_5 = append(Platforms_1_4, []Platform{
-		{"darwin", "arm", false},
-		{"darwin", "arm64", false},
-		{"linux", "arm64", false},
-		{"linux", "ppc64", false},
-		{"linux", "ppc64le", false},
-	}...)
-
-	Platforms_1_6 = append(Platforms_1_5, []Platform{
-		{"android", "386", false},
-		{"linux", "mips64", false},
-		{"linux", "mips64le", false},
-	}...)
-
-	Platforms_1_7 = append(Platforms_1_5, []Platform{
-		// While not fully supported s390x is generally useful
-		{"linux", "s390x", true},
-		{"plan9", "arm", false},
-		// Add the 1.6 Platforms, but reflect full support for mips64 and mips64le
-		{"android", "386", false},
-		{"linux", "mips64", true},
-		{"linux", "mips64le", true},
-	}...)
-
-	Platforms_1_8 = append(Platforms_1_7, []Platform{
-		{"linux", "mips", true},
-		{"linux", "mipsle", true},
-		{"linux", "arm64", true},
-		//{"windows", "arm", true},  //not yet 
-		//{"windows", "arm64", true}, //wont yet cross compile 
-
-	}...)
-
-	Platforms_1_9 = append(Platforms_1_8, []Platform{
-		{"linux", "riscv64", true},
-		{"freebsd", "riscv64", true},
-		{"freebsd", "arm64", true},
-		{"freebsd", "arm", true},
-		{"openbsd", "arm64", true},
-		{"openbsd", "arm", true},
-		{"openbsd", "riscv64", true},
-		{"windows", "arm", true},
-		{"windows", "arm64", true},
-		{"js", "wasm", true},
-	}...)
+	Platforms_1_1 = extendPlatforms(Platforms_1_0,
+		Platform{"freebsd", "arm", false},
+		Platform{"netbsd", "386", false},
+		Platform{"netbsd", "amd64", false},
+		Platform{"netbsd", "arm", false},
+		Platform{"plan9", "386", false},
+	)
+
+	Platforms_1_3 = extendPlatforms(Platforms_1_1,
+		Platform{"dragonfly", "386", false},
+		Platform{"dragonfly", "amd64", false},
+		Platform{"nacl", "amd64", false},
+		Platform{"nacl", "amd64p32", false},
+		Platform{"nacl", "arm", false},
+		Platform{"solaris", "amd64", false},
+	)
+
+	Platforms_1_4 = extendPlatforms(Platforms_1_3,
+		Platform{"android", "arm", false},
+		Platform{"plan9", "amd64", false},
+	)
+
+	Platforms_1_5 = extendPlatforms(Platforms_1_4,
+		Platform{"darwin", "arm", false},
+		Platform{"darwin", "arm64", false},
+		Platform{"linux", "arm64", false},
+		Platform{"linux", "ppc64", false},
+		Platform{"linux", "ppc64le", false},
+		Platform{"openbsd", "arm", false},
+	)
+
+	Platforms_1_6 = extendPlatforms(Platforms_1_5,
+		Platform{"android", "386", false},
+		Platform{"linux", "mips64", false},
+		Platform{"linux", "mips64le", false},
+	)
+
+	Platforms_1_7 = extendPlatforms(Platforms_1_6,
+		Platform{"linux", "s390x", false},
+		Platform{"plan9", "arm", false},
+	)
+
+	Platforms_1_8 = extendPlatforms(firstClassFrom(Platforms_1_7, "linux/arm64"),
+		Platform{"linux", "mips", false},
+		Platform{"linux", "mipsle", false},
+	)
+
+	// no new platforms in 1.9
+	Platforms_1_9 = Platforms_1_8
+
 	// no new platforms in 1.10
 	Platforms_1_10 = Platforms_1_9
 
-	Platforms_1_11 = append(Platforms_1_10, []Platform{
-		{"js", "wasm", true},
-		// Not sure arm64 was ported in 1.11 maybe before!
-		{"linux", "arm64", true},
-	}...)
+	Platforms_1_11 = extendPlatforms(Platforms_1_10,
+		Platform{"js", "wasm", false},
+	)
 
-	Platforms_1_12 = append(Platforms_1_11, []Platform{
-		{"linux", "ppc64", true},
-		{"windows", "arm", true},
-		{"aix", "ppc64", true},
-	}...)
+	Platforms_1_12 = extendPlatforms(Platforms_1_11,
+		Platform{"aix", "ppc64", false},
+		Platform{"windows", "arm", false},
+	)
 
 	PlatformsLatest = Platforms_1_12
 )
 
+// extendPlatforms returns a new table with the platforms of base followed
+// by added, leaving base untouched.
+func extendPlatforms(base []Platform, added ...Platform) []Platform {
+	result := make([]Platform, 0, len(base)+len(added))
+	result = append(result, base...)
+	return append(result, added...)
+}
+
+// firstClassFrom returns a copy of the table with the given os/arch pairs
+// made first class, and so default, for the versions that follow.
+func firstClassFrom(base []Platform, osarch ...string) []Platform {
+	result := clonePlatforms(base)
+	for i := range result {
+		for _, s := range osarch {
+			if result[i].String() == s {
+				result[i].Default = true
+			}
+		}
+	}
+
+	return result
+}
+
+// platformsNewestKnown is the newest Go version that has a platform table
+// above. Keep this in sync with PlatformsLatest.
+const platformsNewestKnown = "1.12"
+
+// platformsOutdated reports whether the Go version v is newer than the
+// newest platform table, meaning the tables may be missing new platforms
+// or still list removed ones. Versions that can't be parsed, such as
+// development builds, are treated as newer.
+func platformsOutdated(v string) bool {
+	if !strings.HasPrefix(v, "go") {
+		return true
+	}
+
+	current, err := version.NewVersion(v[2:])
+	if err != nil {
+		return true
+	}
+	newest := version.Must(version.NewVersion(platformsNewestKnown))
+
+	cs, ns := current.Segments(), newest.Segments()
+	if cs[0] != ns[0] {
+		return cs[0] > ns[0]
+	}
+	return cs[1] > ns[1]
+}
+
+// resolveSupportedPlatforms returns the platforms supported by Go version
+// v. If v is newer than the tables in this package know about, the list
+// from `go tool dist list` is used instead, falling back to the tables
+// only if that fails. The go command is run with GOTOOLCHAIN set to
+// toolchain, unless it is empty.
+func resolveSupportedPlatforms(v, toolchain string, verbose bool) []Platform {
+	if platformsOutdated(v) {
+		platforms, err := distListPlatforms(toolchain)
+		if err == nil {
+			if verbose {
+				fmt.Printf("%s is newer than the known platform tables, "+
+					"using `go tool dist list` for supported platforms.\n", v)
+			}
+			return platforms
+		}
+
+		if verbose {
+			fmt.Printf("Error running `go tool dist list`, using the "+
+				"platform table for Go %s: %s\n", platformsNewestKnown, err)
+		}
+	} else if verbose {
+		fmt.Printf("Using the known platform table for %s.\n", v)
+	}
+
+	return SupportedPlatforms(v)
+}
+
+// platformsOf returns the platforms of the Go release v, such as go1.16.
+// Versions past the platform tables are asked of that release itself,
+// with `go tool dist list` through GOTOOLCHAIN, which fails if the go
+// command can't switch to it.
+func platformsOf(v string) ([]Platform, error) {
+	if !platformsOutdated(v) {
+		return SupportedPlatforms(v), nil
+	}
+
+	toolchain := releaseToolchain(v)
+	platforms, err := distListPlatforms(toolchain)
+	if err != nil {
+		return nil, fmt.Errorf("gox only knows the platforms up to go%s, and "+
+			"`go tool dist list` with GOTOOLCHAIN=%s failed: %s",
+			platformsNewestKnown, toolchain, err)
+	}
+
+	return platforms, nil
+}
+
+// releaseToolchain returns the GOTOOLCHAIN name of the Go release v. From
+// Go 1.21 on the first release of go1.N is named go1.N.0.
+func releaseToolchain(v string) string {
+	current, err := version.NewVersion(strings.TrimPrefix(v, "go"))
+	if err != nil {
+		return v
+	}
+
+	segments := current.Segments()
+	if strings.Count(v, ".") == 1 && current.Prerelease() == "" &&
+		(segments[0] > 1 || segments[1] >= 21) {
+		return v + ".0"
+	}
+
+	return v
+}
+
 // SupportedPlatforms returns the full list of supported platforms for
-// the version of Go that is
+// the version of Go that is given. The returned slice is a copy that is
+// safe to modify; it never aliases the Platforms_* tables.
 func SupportedPlatforms(v string) []Platform {
+	return clonePlatforms(supportedPlatforms(v))
+}
+
+// PlatformsAdded returns the platforms of current, the platforms of some
+// Go version, that the Go version since didn't have. The platforms of
+// since are found with platformsOf.
+func PlatformsAdded(since string, current []Platform) ([]Platform, error) {
+	old, err := platformsOf(since)
+	if err != nil {
+		return nil, fmt.Errorf("the platforms of %s aren't known: %s", since, err)
+	}
+
+	added, _ := DiffMatrix(current, old)
+	return added, nil
+}
+
+// recentPlatforms returns the platforms of supported, the platforms of
+// the Go version v, that were added in the last n releases of Go up to
+// v. They are all marked as a default, so that they are selected unless
+// the selection flags say otherwise.
+func recentPlatforms(v string, n int, supported []Platform) ([]Platform, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("-recent-platforms must be at least 1")
+	}
+
+	current, err := version.NewVersion(strings.TrimPrefix(v, "go"))
+	if err != nil || !strings.HasPrefix(v, "go") {
+		return nil, fmt.Errorf("-recent-platforms can't tell which release %s is", v)
+	}
+
+	segments := current.Segments()
+	if segments[1]-n < 0 {
+		return nil, fmt.Errorf("-recent-platforms %d goes back before go1.0, "+
+			"%s is only %d releases after it", n, v, segments[1])
+	}
+	since := fmt.Sprintf("go%d.%d", segments[0], segments[1]-n)
+
+	added, err := PlatformsAdded(since, supported)
+	if err != nil {
+		return nil, err
+	}
+	if len(added) == 0 {
+		return nil, fmt.Errorf("no platforms were added between %s and %s", since, v)
+	}
+
+	result := make([]Platform, len(added))
+	for i, p := range added {
+		result[i] = p
+		result[i].Default = true
+	}
+
+	return result, nil
+}
+
+func clonePlatforms(ps []Platform) []Platform {
+	result := make([]Platform, len(ps))
+	for i, p := range ps {
+		result[i] = p.Clone()
+	}
+
+	return result
+}
+
+func supportedPlatforms(v string) []Platform {
 	// Use latest if we get an unexpected version string
 	if !strings.HasPrefix(v, "go") {
 		return PlatformsLatest
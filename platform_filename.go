@@ -0,0 +1,103 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// knownOS and knownArch are every GOOS and GOARCH value Go recognizes in
+// file names and build constraints, including ones it doesn't (or no
+// longer) support. Matches https://github.com/golang/go/blob/master/src/internal/syslist/syslist.go
+var knownOS = map[string]bool{
+	"aix":       true,
+	"android":   true,
+	"darwin":    true,
+	"dragonfly": true,
+	"freebsd":   true,
+	"hurd":      true,
+	"illumos":   true,
+	"ios":       true,
+	"js":        true,
+	"linux":     true,
+	"nacl":      true,
+	"netbsd":    true,
+	"openbsd":   true,
+	"plan9":     true,
+	"solaris":   true,
+	"wasip1":    true,
+	"windows":   true,
+	"zos":       true,
+}
+
+var knownArch = map[string]bool{
+	"386":         true,
+	"amd64":       true,
+	"amd64p32":    true,
+	"arm":         true,
+	"armbe":       true,
+	"arm64":       true,
+	"arm64be":     true,
+	"loong64":     true,
+	"mips":        true,
+	"mipsle":      true,
+	"mips64":      true,
+	"mips64le":    true,
+	"mips64p32":   true,
+	"mips64p32le": true,
+	"ppc":         true,
+	"ppc64":       true,
+	"ppc64le":     true,
+	"riscv":       true,
+	"riscv64":     true,
+	"s390":        true,
+	"s390x":       true,
+	"sparc":       true,
+	"sparc64":     true,
+	"wasm":        true,
+}
+
+// PlatformFromFilename returns the platform implied by a file name using
+// Go's naming conventions:
+//
+//	name_GOOS.go
+//	name_GOARCH.go
+//	name_GOOS_GOARCH.go
+//
+// along with the same names ending in _test.go. Any directory and
+// extension are ignored. If the name only constrains the OS or only the
+// arch, the other field of the returned platform is empty. The boolean is
+// false if the name doesn't imply a platform at all.
+//
+// A single suffix is taken as an OS if it is a known GOOS and an arch if
+// it is a known GOARCH; no value is both. Like the go tool, everything up
+// to the first underscore is the name itself, so "linux.go" has no
+// constraint but "x_linux.go" does.
+func PlatformFromFilename(name string) (Platform, bool) {
+	name = filepath.Base(name)
+	if idx := strings.Index(name, "."); idx >= 0 {
+		name = name[:idx]
+	}
+
+	idx := strings.Index(name, "_")
+	if idx < 0 {
+		return Platform{}, false
+	}
+
+	l := strings.Split(name[idx:], "_")
+	if n := len(l); n > 0 && l[n-1] == "test" {
+		l = l[:n-1]
+	}
+
+	n := len(l)
+	if n >= 2 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+		return Platform{OS: l[n-2], Arch: l[n-1]}, true
+	}
+	if n >= 1 && knownOS[l[n-1]] {
+		return Platform{OS: l[n-1]}, true
+	}
+	if n >= 1 && knownArch[l[n-1]] {
+		return Platform{Arch: l[n-1]}, true
+	}
+
+	return Platform{}, false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPlatformFromFilename(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Platform Platform
+		Found    bool
+	}{
+		// No constraint
+		{"main.go", Platform{}, false},
+		{"main_test.go", Platform{}, false},
+		{"foo_bar.go", Platform{}, false},
+		{"foo_bar_test.go", Platform{}, false},
+
+		// The name before the first underscore is never a constraint
+		{"linux.go", Platform{}, false},
+		{"amd64.go", Platform{}, false},
+		{"linux_test.go", Platform{}, false},
+
+		// OS only
+		{"foo_linux.go", Platform{OS: "linux"}, true},
+		{"foo_windows_test.go", Platform{OS: "windows"}, true},
+		{"_linux.go", Platform{OS: "linux"}, true},
+		{"foo_bar_darwin.go", Platform{OS: "darwin"}, true},
+
+		// Arch only
+		{"foo_amd64.go", Platform{Arch: "amd64"}, true},
+		{"foo_arm64_test.go", Platform{Arch: "arm64"}, true},
+		{"foo_wasm.s", Platform{Arch: "wasm"}, true},
+
+		// OS and arch
+		{"foo_linux_amd64.go", Platform{OS: "linux", Arch: "amd64"}, true},
+		{"foo_js_wasm_test.go", Platform{OS: "js", Arch: "wasm"}, true},
+		{"foo_bar_windows_386.go", Platform{OS: "windows", Arch: "386"}, true},
+		{"dir/sub/foo_freebsd_arm.go", Platform{OS: "freebsd", Arch: "arm"}, true},
+
+		// An arch followed by an OS is only the OS
+		{"foo_amd64_linux.go", Platform{OS: "linux"}, true},
+
+		// Two OSes, or an OS and an unknown arch, is only the last OS
+		{"foo_linux_windows.go", Platform{OS: "windows"}, true},
+		{"foo_linux_x64.go", Platform{}, false},
+
+		// Extensions after the first dot are ignored
+		{"foo_linux_amd64.pb.go", Platform{OS: "linux", Arch: "amd64"}, true},
+
+		// Unknown values
+		{"foo_macos.go", Platform{}, false},
+		{"foo_x64.go", Platform{}, false},
+		{"foo_test_linux.go", Platform{OS: "linux"}, true},
+	}
+
+	for _, tc := range cases {
+		p, ok := PlatformFromFilename(tc.Name)
+		if p != tc.Platform || ok != tc.Found {
+			t.Fatalf("%s: bad: %#v %v", tc.Name, p, ok)
+		}
+	}
+}
@@ -16,7 +16,8 @@ type PlatformFlag struct {
 }
 
 // Platforms returns the list of platforms that were set by this flag.
-// The default set of platforms must be passed in.
+// The default set of platforms must be passed in. The returned slice is
+// always newly allocated and safe to modify.
 func (p *PlatformFlag) Platforms(supported []Platform) []Platform {
 	// NOTE: Reading this method alone is a bit hard to understand. It
 	// is much easier to understand this method if you pair this with the
@@ -183,6 +184,130 @@ func (p *PlatformFlag) Platforms(supported []Platform) []Platform {
 	return result
 }
 
+// Unsupported returns what was explicitly asked for but can't be built
+// with the supported platforms: -osarch pairs that aren't supported, and
+// -os or -arch values without any supported platform. Combinations of -os
+// and -arch values that aren't supported are not included, since only
+// the supported ones are meant to be built.
+func (p *PlatformFlag) Unsupported(supported []Platform) []string {
+	supportedOSArch := make(map[string]struct{})
+	supportedOS := make(map[string]struct{})
+	supportedArch := make(map[string]struct{})
+	for _, s := range supported {
+		supportedOSArch[s.String()] = struct{}{}
+		supportedOS[s.OS] = struct{}{}
+		supportedArch[s.Arch] = struct{}{}
+	}
+
+	var result []string
+	for _, v := range p.OSArch {
+		if v.OS[0] == '!' {
+			continue
+		}
+		if _, ok := supportedOSArch[v.String()]; !ok {
+			result = append(result, v.String())
+		}
+	}
+	for _, v := range p.OS {
+		if _, ok := supportedOS[v]; !ok && v[0] != '!' {
+			result = append(result, "-os "+v)
+		}
+	}
+	for _, v := range p.Arch {
+		if _, ok := supportedArch[v]; !ok && v[0] != '!' {
+			result = append(result, "-arch "+v)
+		}
+	}
+
+	return result
+}
+
+// Only returns the single platform given by an os/arch pair, such as
+// "linux/amd64", bypassing the usual selection of platforms. The pair
+// must be one of the supported platforms, and it can't be combined with
+// any other way of selecting platforms.
+func (p *PlatformFlag) Only(osarch string, supported []Platform) ([]Platform, error) {
+	if len(p.OS) > 0 || len(p.Arch) > 0 || len(p.OSArch) > 0 || p.All {
+		return nil, fmt.Errorf(
+			"-only can't be combined with -os, -arch, -osarch or -all")
+	}
+
+	parts := strings.Split(osarch, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf(
+			"invalid -only platform %q, it should be os/arch", osarch)
+	}
+
+	for _, s := range supported {
+		if s.OS == parts[0] && s.Arch == parts[1] {
+			return []Platform{s.Clone()}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s is not supported by this version of Go", osarch)
+}
+
+// withoutPlatform returns platforms without the given platform, and
+// whether it was removed.
+func withoutPlatform(platforms []Platform, p Platform) ([]Platform, bool) {
+	result := make([]Platform, 0, len(platforms))
+	removed := false
+	for _, candidate := range platforms {
+		if candidate.OS == p.OS && candidate.Arch == p.Arch {
+			removed = true
+			continue
+		}
+		result = append(result, candidate)
+	}
+
+	return result, removed
+}
+
+// missingPlatforms returns the platforms that aren't in supported, as
+// os/arch strings.
+func missingPlatforms(platforms, supported []Platform) []string {
+	unsupported, _ := DiffMatrix(platforms, supported)
+
+	var result []string
+	for _, p := range unsupported {
+		result = append(result, p.String())
+	}
+
+	return result
+}
+
+// DiffMatrix compares the requested platforms with the supported ones. It
+// returns the requested platforms that aren't supported, and the
+// supported platforms that weren't requested, each in the order of its
+// input. OS and arch are compared case-insensitively.
+func DiffMatrix(requested, supported []Platform) (unsupported, notRequested []Platform) {
+	key := func(p Platform) string {
+		return strings.ToLower(p.OS) + "/" + strings.ToLower(p.Arch)
+	}
+
+	inRequested := make(map[string]bool, len(requested))
+	for _, p := range requested {
+		inRequested[key(p)] = true
+	}
+	inSupported := make(map[string]bool, len(supported))
+	for _, p := range supported {
+		inSupported[key(p)] = true
+	}
+
+	for _, p := range requested {
+		if !inSupported[key(p)] {
+			unsupported = append(unsupported, p)
+		}
+	}
+	for _, p := range supported {
+		if !inRequested[key(p)] {
+			notRequested = append(notRequested, p)
+		}
+	}
+
+	return unsupported, notRequested
+}
+
 // ArchFlagValue returns a flag.Value that can be used with the flag
 // package to collect the arches for the flag.
 func (p *PlatformFlag) ArchFlagValue() flag.Value {
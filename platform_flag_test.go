@@ -321,3 +321,129 @@ func TestAppendStringValue(t *testing.T) {
 		t.Fatalf("bad: %#v", value)
 	}
 }
+
+func TestPlatformFlagOnly(t *testing.T) {
+	supported := []Platform{
+		{OS: "linux", Arch: "amd64", Default: true},
+		{OS: "plan9", Arch: "386"},
+	}
+
+	var f PlatformFlag
+	actual, err := f.Only("plan9/386", supported)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, []Platform{{OS: "plan9", Arch: "386"}}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	for _, osarch := range []string{"linux", "linux/", "linux/amd64/v2", "windows/amd64"} {
+		if _, err := f.Only(osarch, supported); err == nil {
+			t.Fatalf("%s: should error", osarch)
+		}
+	}
+
+	f.OS = []string{"linux"}
+	if _, err := f.Only("linux/amd64", supported); err == nil {
+		t.Fatal("should error when combined with -os")
+	}
+}
+
+func TestWithoutPlatform(t *testing.T) {
+	platforms := []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "darwin", Arch: "arm64"},
+	}
+
+	actual, removed := withoutPlatform(platforms, Platform{OS: "linux", Arch: "amd64"})
+	if !removed || !reflect.DeepEqual(actual, []Platform{{OS: "darwin", Arch: "arm64"}}) {
+		t.Fatalf("bad: %#v %v", actual, removed)
+	}
+
+	actual, removed = withoutPlatform(platforms, Platform{OS: "windows", Arch: "amd64"})
+	if removed || !reflect.DeepEqual(actual, platforms) {
+		t.Fatalf("bad: %#v %v", actual, removed)
+	}
+}
+
+func TestPlatformFlagUnsupported(t *testing.T) {
+	supported := []Platform{
+		{OS: "linux", Arch: "amd64", Default: true},
+		{OS: "darwin", Arch: "arm64", Default: true},
+	}
+
+	f := PlatformFlag{
+		OS:   []string{"linux", "darwin", "plan9", "!aix"},
+		Arch: []string{"amd64", "mips"},
+		OSArch: []Platform{
+			{OS: "linux", Arch: "amd64"},
+			{OS: "darwin", Arch: "386"},
+			{OS: "!windows", Arch: "386"},
+		},
+	}
+
+	expected := []string{"darwin/386", "-os plan9", "-arch mips"}
+	if actual := f.Unsupported(supported); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	f = PlatformFlag{OS: []string{"linux", "darwin"}, Arch: []string{"amd64", "arm64"}}
+	if actual := f.Unsupported(supported); len(actual) > 0 {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestMissingPlatforms(t *testing.T) {
+	platforms := []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "darwin", Arch: "386"},
+		{OS: "nacl", Arch: "arm"},
+	}
+	supported := []Platform{
+		{OS: "linux", Arch: "amd64", Default: true},
+		{OS: "darwin", Arch: "arm64", Default: true},
+	}
+
+	expected := []string{"darwin/386", "nacl/arm"}
+	if actual := missingPlatforms(platforms, supported); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestDiffMatrix(t *testing.T) {
+	// Overlapping, with case differences
+	requested := []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "Darwin", Arch: "ARM64"},
+		{OS: "plan9", Arch: "arm64"},
+	}
+	supported := []Platform{
+		{OS: "darwin", Arch: "arm64"},
+		{OS: "linux", Arch: "amd64"},
+		{OS: "windows", Arch: "amd64"},
+	}
+
+	unsupported, notRequested := DiffMatrix(requested, supported)
+	if !reflect.DeepEqual(unsupported, []Platform{{OS: "plan9", Arch: "arm64"}}) {
+		t.Fatalf("bad: %#v", unsupported)
+	}
+	if !reflect.DeepEqual(notRequested, []Platform{{OS: "windows", Arch: "amd64"}}) {
+		t.Fatalf("bad: %#v", notRequested)
+	}
+
+	// Disjoint
+	requested = []Platform{{OS: "plan9", Arch: "386"}}
+	unsupported, notRequested = DiffMatrix(requested, supported)
+	if !reflect.DeepEqual(unsupported, requested) {
+		t.Fatalf("bad: %#v", unsupported)
+	}
+	if !reflect.DeepEqual(notRequested, supported) {
+		t.Fatalf("bad: %#v", notRequested)
+	}
+
+	// Identical
+	unsupported, notRequested = DiffMatrix(supported, supported)
+	if len(unsupported) != 0 || len(notRequested) != 0 {
+		t.Fatalf("bad: %#v %#v", unsupported, notRequested)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PlatformOverrideFlag is a flag.Value that collects per-platform values
+// keyed by an os/arch glob, for example "windows/*=myapp-setup.exe". The
+// flag may be given multiple times. If more than one pattern matches a
+// platform, the one given last wins.
+type PlatformOverrideFlag struct {
+	patterns []string
+	values   []string
+}
+
+// Set parses a single "pattern=value" pair. The pattern uses the syntax
+// of path.Match and is matched against the "os/arch" string of a platform.
+func (f *PlatformOverrideFlag) Set(s string) error {
+	idx := strings.Index(s, "=")
+	if idx <= 0 {
+		return fmt.Errorf(
+			"invalid format: %s should be os/arch=value", s)
+	}
+
+	pattern := strings.ToLower(s[:idx])
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid platform pattern %q: %s", pattern, err)
+	}
+
+	f.patterns = append(f.patterns, pattern)
+	f.values = append(f.values, s[idx+1:])
+	return nil
+}
+
+func (f *PlatformOverrideFlag) String() string {
+	pairs := make([]string, len(f.patterns))
+	for i, p := range f.patterns {
+		pairs[i] = p + "=" + f.values[i]
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// Lookup returns the value for the last pattern matching the platform.
+func (f *PlatformOverrideFlag) Lookup(p Platform) (string, bool) {
+	for i := len(f.patterns) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(f.patterns[i], p.String()); ok {
+			return f.values[i], true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestPlatformOverrideFlag_impl(t *testing.T) {
+	var _ flag.Value = new(PlatformOverrideFlag)
+}
+
+func TestPlatformOverrideFlag(t *testing.T) {
+	var f PlatformOverrideFlag
+
+	if err := f.Set("windows"); err == nil {
+		t.Fatal("should err")
+	}
+
+	if err := f.Set("=foo"); err == nil {
+		t.Fatal("should err")
+	}
+
+	if err := f.Set("[/*=foo"); err == nil {
+		t.Fatal("should err")
+	}
+
+	if err := f.Set("windows/*=myapp-setup.exe"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := f.Set("Windows/ARM64=myapp-arm64.exe"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Platform Platform
+		Value    string
+		Found    bool
+	}{
+		{Platform{OS: "windows", Arch: "386"}, "myapp-setup.exe", true},
+		{Platform{OS: "windows", Arch: "arm64"}, "myapp-arm64.exe", true},
+		{Platform{OS: "linux", Arch: "386"}, "", false},
+	}
+
+	for _, tc := range cases {
+		v, ok := f.Lookup(tc.Platform)
+		if v != tc.Value || ok != tc.Found {
+			t.Fatalf("%s: bad: %q %v", tc.Platform.String(), v, ok)
+		}
+	}
+}
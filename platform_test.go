@@ -1,8 +1,11 @@
 package main
 
 import (
+	"os"
 	"reflect"
 	"testing"
+
+	version "github.com/hashicorp/go-version"
 )
 
 func TestSupportedPlatforms(t *testing.T) {
@@ -81,18 +84,499 @@ func TestSupportedPlatforms(t *testing.T) {
 }
 
 func TestMIPS(t *testing.T) {
-	g16 := SupportedPlatforms("go1.6")
-	for _, p := range g16 {
-		if p.Arch == "mips64" && p.Default {
-			t.Fatal("mips64 should not be default for 1.6")
+	for _, v := range []string{"go1.6", "go1.7"} {
+		found := false
+		for _, p := range SupportedPlatforms(v) {
+			if p.Arch == "mips64" {
+				found = true
+				if p.Default {
+					t.Fatalf("mips64 should not be default for %s", v)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("mips64 should be supported by %s", v)
+		}
+	}
+}
+
+// TestPlatformIsFirstClassDefault checks the Default flags of each platform
+// table against the first class ports of its version of Go.
+func TestPlatformIsFirstClassDefault(t *testing.T) {
+	firstClass := map[string]bool{
+		"darwin/386":    true,
+		"darwin/amd64":  true,
+		"linux/386":     true,
+		"linux/amd64":   true,
+		"linux/arm":     true,
+		"windows/386":   true,
+		"windows/amd64": true,
+	}
+
+	tables := []struct {
+		version string
+		table   []Platform
+	}{
+		{"1.0", Platforms_1_0},
+		{"1.1", Platforms_1_1},
+		{"1.3", Platforms_1_3},
+		{"1.4", Platforms_1_4},
+		{"1.5", Platforms_1_5},
+		{"1.6", Platforms_1_6},
+		{"1.7", Platforms_1_7},
+		{"1.8", Platforms_1_8},
+		{"1.9", Platforms_1_9},
+		{"1.10", Platforms_1_10},
+		{"1.11", Platforms_1_11},
+		{"1.12", Platforms_1_12},
+	}
+
+	for _, tc := range tables {
+		// linux/arm64 became a first class port in Go 1.8
+		if tc.version == "1.8" {
+			firstClass["linux/arm64"] = true
+		}
+
+		seen := make(map[string]bool)
+		for _, p := range tc.table {
+			if seen[p.String()] {
+				t.Errorf("%s: %s is listed more than once", tc.version, p.String())
+			}
+			seen[p.String()] = true
+
+			if p.Default != firstClass[p.String()] {
+				t.Errorf("%s: %s has Default %v", tc.version, p.String(), p.Default)
+			}
+		}
+
+		for osarch := range firstClass {
+			if !seen[osarch] {
+				t.Errorf("%s: first class port %s is missing", tc.version, osarch)
+			}
+		}
+	}
+}
+
+// TestPlatformTablesAddedIn checks that the platform tables only list ports
+// from the release that added them on.
+func TestPlatformTablesAddedIn(t *testing.T) {
+	addedIn := map[string]string{
+		"openbsd/arm":     "1.5",
+		"js/wasm":         "1.11",
+		"aix/ppc64":       "1.12",
+		"windows/arm":     "1.12",
+		"openbsd/arm64":   "1.13",
+		"freebsd/arm64":   "1.14",
+		"linux/riscv64":   "1.14",
+		"windows/arm64":   "1.17",
+		"freebsd/riscv64": "1.20",
+		"openbsd/riscv64": "1.23",
+	}
+
+	tables := map[string][]Platform{
+		"1.4":  Platforms_1_4,
+		"1.5":  Platforms_1_5,
+		"1.9":  Platforms_1_9,
+		"1.10": Platforms_1_10,
+		"1.11": Platforms_1_11,
+		"1.12": Platforms_1_12,
+	}
+
+	for v, table := range tables {
+		current := version.Must(version.NewVersion(v))
+		listed := make(map[string]bool)
+		for _, p := range table {
+			listed[p.String()] = true
+		}
+
+		for osarch, since := range addedIn {
+			expected := !current.LessThan(version.Must(version.NewVersion(since)))
+			if listed[osarch] != expected {
+				t.Fatalf("%s: %s listed is %v, added in %s", v, osarch, listed[osarch], since)
+			}
+		}
+	}
+}
+
+func TestPlatformsOutdated(t *testing.T) {
+	cases := map[string]bool{
+		"go1.4":       false,
+		"go1.12":      false,
+		"go1.12.17":   false,
+		"go1.13":      true,
+		"go1.21.4":    true,
+		"go2.0":       true,
+		"devel +abcd": true,
+	}
+
+	for v, expected := range cases {
+		if actual := platformsOutdated(v); actual != expected {
+			t.Fatalf("%s: expected %v", v, expected)
+		}
+	}
+}
+
+func TestPlatformClone(t *testing.T) {
+	p := Platform{OS: "linux", Arch: "amd64", Default: true}
+	c := p.Clone()
+	c.OS = "windows"
+	if p.OS != "linux" {
+		t.Fatalf("bad: %#v", p)
+	}
+}
+
+// Every public accessor returning platforms must hand out an independent
+// copy so that callers can't corrupt the tables or each other's results.
+func TestPlatformAccessorsReturnCopies(t *testing.T) {
+	versions := []string{
+		"go1.0", "go1.1", "go1.3", "go1.4", "go1.5", "go1.6", "go1.7",
+		"go1.8", "go1.9", "go1.10", "go1.11", "go1.12", "foo",
+	}
+
+	expected := make(map[string][]Platform)
+	for _, v := range versions {
+		expected[v] = clonePlatforms(supportedPlatforms(v))
+	}
+
+	mutate := func(ps []Platform) {
+		for i := range ps {
+			ps[i].OS = "mutated"
+			ps[i].Arch = "mutated"
+			ps[i].Default = !ps[i].Default
+		}
+	}
+
+	for _, v := range versions {
+		ps := SupportedPlatforms(v)
+
+		var flag PlatformFlag
+		flag.All = true
+		mutate(flag.Platforms(ps))
+
+		mutate(ps)
+	}
+
+	ps, err := parseDistList("linux/amd64\n")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	mutate(ps)
+
+	for _, v := range versions {
+		if actual := SupportedPlatforms(v); !reflect.DeepEqual(actual, expected[v]) {
+			t.Fatalf("%s: bad: %#v", v, actual)
+		}
+	}
+	for _, p := range PlatformsLatest {
+		if p.OS == "mutated" {
+			t.Fatalf("bad: %#v", PlatformsLatest)
+		}
+	}
+}
+
+func TestPlatformSupportsRace(t *testing.T) {
+	cases := []struct {
+		Platform  Platform
+		GoVersion string
+		Expected  bool
+	}{
+		{Platform{OS: "linux", Arch: "amd64"}, "go1.1", true},
+		{Platform{OS: "linux", Arch: "arm64"}, "go1.11.13", false},
+		{Platform{OS: "linux", Arch: "arm64"}, "go1.12", true},
+		{Platform{OS: "linux", Arch: "ppc64le"}, "go1.21.4", true},
+		{Platform{OS: "linux", Arch: "s390x"}, "go1.18.10", false},
+		{Platform{OS: "linux", Arch: "s390x"}, "go1.19", true},
+		{Platform{OS: "linux", Arch: "riscv64"}, "go1.25.0", false},
+		{Platform{OS: "linux", Arch: "riscv64"}, "devel +abc123", true},
+		{Platform{OS: "darwin", Arch: "arm64"}, "go1.16", true},
+		{Platform{OS: "windows", Arch: "amd64"}, "go1.21.4", true},
+		{Platform{OS: "freebsd", Arch: "amd64"}, "go1.21.4", true},
+		{Platform{OS: "linux", Arch: "386"}, "go1.21.4", false},
+		{Platform{OS: "linux", Arch: "arm"}, "go1.21.4", false},
+		{Platform{OS: "linux", Arch: "ppc64"}, "go1.21.4", false},
+		{Platform{OS: "windows", Arch: "386"}, "go1.21.4", false},
+		{Platform{OS: "windows", Arch: "arm64"}, "go1.21.4", false},
+		{Platform{OS: "freebsd", Arch: "arm64"}, "go1.21.4", false},
+		{Platform{OS: "js", Arch: "wasm"}, "devel +abc123", false},
+	}
+
+	for _, tc := range cases {
+		if actual := tc.Platform.SupportsRace(tc.GoVersion); actual != tc.Expected {
+			t.Fatalf("%s %s: expected %v", tc.Platform.String(), tc.GoVersion, tc.Expected)
+		}
+	}
+}
+
+func TestPlatformFirstClass(t *testing.T) {
+	if distFirstClassPorts() == nil {
+		t.Skip("go tool dist list -json is not available")
+	}
+
+	cases := map[Platform]bool{
+		{OS: "linux", Arch: "amd64"}:                true,
+		{OS: "windows", Arch: "amd64"}:              true,
+		{OS: "aix", Arch: "ppc64"}:                  false,
+		{OS: "plan9", Arch: "386"}:                  false,
+		{OS: "bogus", Arch: "bogus", Default: true}: false,
+	}
+
+	for p, expected := range cases {
+		if actual := p.FirstClass(); actual != expected {
+			t.Fatalf("%s: expected %v", p.String(), expected)
+		}
+	}
+}
+
+func TestPlatformExperimental(t *testing.T) {
+	cases := []struct {
+		Platform  Platform
+		GoVersion string
+		Expected  bool
+	}{
+		{Platform{OS: "linux", Arch: "riscv64"}, "go1.13.15", false},
+		{Platform{OS: "linux", Arch: "riscv64"}, "go1.14", true},
+		{Platform{OS: "linux", Arch: "riscv64"}, "go1.15.8", true},
+		{Platform{OS: "linux", Arch: "riscv64"}, "go1.16", false},
+		{Platform{OS: "linux", Arch: "riscv64"}, "go1.16rc1", false},
+		{Platform{OS: "wasip1", Arch: "wasm"}, "go1.21rc2", true},
+		{Platform{OS: "wasip1", Arch: "wasm"}, "go1.23.1", true},
+		{Platform{OS: "wasip1", Arch: "wasm"}, "devel +abc123", true},
+		{Platform{OS: "linux", Arch: "riscv64"}, "devel +abc123", false},
+		{Platform{OS: "openbsd", Arch: "riscv64"}, "go1.22.5", false},
+		{Platform{OS: "openbsd", Arch: "riscv64"}, "go1.23", true},
+		{Platform{OS: "linux", Arch: "amd64"}, "go1.21", false},
+	}
+
+	for _, tc := range cases {
+		if actual := tc.Platform.Experimental(tc.GoVersion); actual != tc.Expected {
+			t.Fatalf("%s in %s: expected %v", tc.Platform.String(), tc.GoVersion, tc.Expected)
+		}
+	}
+}
+
+func TestPlatformDockerPlatform(t *testing.T) {
+	defer os.Setenv("GOARM", os.Getenv("GOARM"))
+
+	cases := []struct {
+		Platform Platform
+		GOARM    string
+		Expected string
+	}{
+		{Platform{OS: "linux", Arch: "amd64"}, "", "linux/amd64"},
+		{Platform{OS: "linux", Arch: "arm64"}, "", "linux/arm64/v8"},
+		{Platform{OS: "linux", Arch: "arm"}, "", "linux/arm/v7"},
+		{Platform{OS: "linux", Arch: "arm"}, "6", "linux/arm/v6"},
+		{Platform{OS: "linux", Arch: "arm"}, "7,softfloat", "linux/arm/v7"},
+		{Platform{OS: "windows", Arch: "amd64"}, "", "windows/amd64"},
+		{Platform{OS: "darwin", Arch: "arm64"}, "", ""},
+		{Platform{OS: "js", Arch: "wasm"}, "", ""},
+	}
+
+	for _, tc := range cases {
+		os.Setenv("GOARM", tc.GOARM)
+		if actual := tc.Platform.DockerPlatform(); actual != tc.Expected {
+			t.Fatalf("%s: expected %q, got %q", tc.Platform.String(), tc.Expected, actual)
+		}
+	}
+}
+
+func TestPlatformDistroArch(t *testing.T) {
+	defer os.Setenv("GOARM", os.Getenv("GOARM"))
+
+	cases := []struct {
+		Arch   string
+		GOARM  string
+		Debian string
+		RPM    string
+	}{
+		{"386", "", "i386", "i686"},
+		{"amd64", "", "amd64", "x86_64"},
+		{"arm64", "", "arm64", "aarch64"},
+		{"arm", "", "armhf", "armv7hl"},
+		{"arm", "7,softfloat", "armhf", "armv7hl"},
+		{"arm", "6", "armel", "armv6hl"},
+		{"arm", "5", "armel", "armv5tel"},
+		{"ppc64le", "", "ppc64el", "ppc64le"},
+		{"mips64le", "", "mips64el", "mips64el"},
+		{"loong64", "", "loong64", "loongarch64"},
+		{"wasm", "", "", ""},
+	}
+
+	for _, tc := range cases {
+		os.Setenv("GOARM", tc.GOARM)
+		p := Platform{OS: "linux", Arch: tc.Arch}
+		if actual := p.DebianArch(); actual != tc.Debian {
+			t.Fatalf("%s GOARM=%s: expected Debian %q, got %q", tc.Arch, tc.GOARM, tc.Debian, actual)
+		}
+		if actual := p.RPMArch(); actual != tc.RPM {
+			t.Fatalf("%s GOARM=%s: expected RPM %q, got %q", tc.Arch, tc.GOARM, tc.RPM, actual)
+		}
+	}
+}
+
+func TestPlatformValidate(t *testing.T) {
+	valid := []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "windows", Arch: "386"},
+		{OS: "nacl", Arch: "amd64p32"},
+	}
+	if distAllPorts() != nil {
+		valid = append(valid, Platform{OS: "wasip1", Arch: "wasm"})
+	}
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Fatalf("%s: err: %s", p.String(), err)
+		}
+	}
+
+	invalid := []struct {
+		Platform Platform
+		Err      string
+	}{
+		{Platform{Arch: "amd64"}, "GOOS is empty"},
+		{Platform{OS: "linux"}, "GOARCH is empty"},
+		{Platform{OS: "Linux", Arch: "amd64"}, `GOOS "Linux" must be lowercase`},
+		{Platform{OS: "linux", Arch: "AMD64"}, `GOARCH "AMD64" must be lowercase`},
+		{Platform{OS: "win", Arch: "amd64"}, `unknown GOOS "win"`},
+		{Platform{OS: "linux", Arch: "x64"}, `unknown GOARCH "x64"`},
+		{Platform{OS: "windows", Arch: "sparc"}, `unknown platform "windows/sparc"`},
+	}
+	for _, tc := range invalid {
+		err := tc.Platform.Validate()
+		if err == nil || err.Error() != tc.Err {
+			t.Fatalf("%s: bad: %v", tc.Platform.String(), err)
+		}
+	}
+}
+
+func TestReleaseToolchain(t *testing.T) {
+	cases := map[string]string{
+		"go1.16":     "go1.16",
+		"go1.20.14":  "go1.20.14",
+		"go1.21":     "go1.21.0",
+		"go1.25":     "go1.25.0",
+		"go1.25.3":   "go1.25.3",
+		"go1.25rc1":  "go1.25rc1",
+		"devel +abc": "devel +abc",
+	}
+
+	for v, expected := range cases {
+		if actual := releaseToolchain(v); actual != expected {
+			t.Fatalf("%s: bad: %s", v, actual)
+		}
+	}
+}
+
+func TestPlatformsOf(t *testing.T) {
+	platforms, err := platformsOf("go1.11")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(platforms, Platforms_1_11) {
+		t.Fatalf("bad: %#v", platforms)
+	}
+
+	// Past the tables, the platforms come from that release's dist list
+	defer seedDistLists(map[string]string{"go1.21.0": "linux/amd64\nwasip1/wasm\n"})()
+
+	platforms, err = platformsOf("go1.21")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(platforms) != 2 || platforms[1].String() != "wasip1/wasm" {
+		t.Fatalf("bad: %#v", platforms)
+	}
+}
+
+func TestPlatformsAdded(t *testing.T) {
+	added, err := PlatformsAdded("go1.11", Platforms_1_12)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(added) != 2 || added[0].String() != "aix/ppc64" || added[1].String() != "windows/arm" {
+		t.Fatalf("bad: %#v", added)
+	}
+
+	// Past the tables, since is asked for its dist list
+	defer seedDistLists(map[string]string{"go1.21.0": "linux/amd64\n"})()
+	added, err = PlatformsAdded("go1.21", []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "openbsd", Arch: "ppc64"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(added) != 1 || added[0].String() != "openbsd/ppc64" {
+		t.Fatalf("bad: %#v", added)
+	}
+
+	if _, err := PlatformsAdded("devel +abc", Platforms_1_12); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// seedDistLists replaces the cached `go tool dist list` outputs with m,
+// and returns a func that restores them.
+func seedDistLists(m map[string]string) func() {
+	distLists.Lock()
+	saved := distLists.m
+	distLists.m = m
+	distLists.Unlock()
+
+	return func() {
+		distLists.Lock()
+		distLists.m = saved
+		distLists.Unlock()
+	}
+}
+
+func TestRecentPlatforms(t *testing.T) {
+	// Go 1.5 and 1.6 added six and three ports
+	recent, err := recentPlatforms("go1.6.4", 2, SupportedPlatforms("go1.6"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(recent) != 9 {
+		t.Fatalf("bad: %#v", recent)
+	}
+	for _, p := range recent {
+		if !p.Default {
+			t.Fatalf("bad: %s isn't a default", p.String())
 		}
 	}
 
-	g17 := SupportedPlatforms("go1.7")
-	for _, p := range g17 {
-		if p.Arch == "mips64" && !p.Default {
-			t.Fatal("mips64 should be default for 1.7")
+	// Going back too far, or from a version that isn't a release
+	errs := []struct {
+		V string
+		N int
+	}{
+		{"go1.6", 0},
+		{"go1.6", 7},
+		{"devel +abc", 1},
+	}
+	for _, tc := range errs {
+		if _, err := recentPlatforms(tc.V, tc.N, PlatformsLatest); err == nil {
+			t.Fatalf("%s %d: should error", tc.V, tc.N)
 		}
 	}
 
+	// A newer Go can go back to the last table
+	if _, err := recentPlatforms("go1.14", 2, append(SupportedPlatforms("go1.12"),
+		Platform{OS: "illumos", Arch: "amd64"})); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Past the tables, the older release is asked for its dist list
+	defer seedDistLists(map[string]string{"go1.25.0": "linux/amd64\nopenbsd/riscv64\n"})()
+	recent, err = recentPlatforms("go1.27.1", 2, []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "openbsd", Arch: "riscv64"},
+		{OS: "windows", Arch: "arm"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(recent) != 1 || recent[0].String() != "windows/arm" {
+		t.Fatalf("bad: %#v", recent)
+	}
 }
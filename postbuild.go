@@ -0,0 +1,21 @@
+package main
+
+// postBuildStep post-processes a successful build, such as signing or
+// packaging it. Steps run in the worker of the build.
+type postBuildStep func(opts *CompileOpts, result *BuildResult) error
+
+// postBuildSteps is a series of steps that run in order. Steps that
+// produce new files must run before the ones that hash them.
+type postBuildSteps []postBuildStep
+
+// Run runs every step, stopping at the first error. It is meant to be
+// used as Builder.PostBuild.
+func (s postBuildSteps) Run(opts *CompileOpts, result *BuildResult) error {
+	for _, step := range s {
+		if err := step(opts, result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
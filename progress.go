@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressEvent is a line written to -progress-fd. Its JSON form is read
+// by other programs, so fields may be added but not changed or removed.
+type progressEvent struct {
+	// Event is "started" when a build starts and "finished" when it ends,
+	// including builds that never started, like skipped or resumed ones.
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Platform  string    `json:"platform"`
+	Package   string    `json:"package"`
+	GoVersion string    `json:"go_version,omitempty"`
+
+	// Status, DurationMs and Error are only set on "finished" events.
+	// DurationMs is left out for builds that didn't start.
+	Status     string `json:"status,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// progressWriter writes progress events as newline-delimited JSON. It is
+// safe for concurrent use, as builds start and finish in their workers.
+type progressWriter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	now     func() time.Time
+	started map[string]time.Time
+
+	// err is the first error writing an event. Later events are dropped.
+	err error
+}
+
+func newProgressWriter(w io.Writer) *progressWriter {
+	return &progressWriter{
+		enc:     json.NewEncoder(w),
+		now:     time.Now,
+		started: make(map[string]time.Time),
+	}
+}
+
+// openProgressFD opens the file descriptor of -progress-fd, which must
+// already be open and can't be stdin.
+func openProgressFD(fd int) (*os.File, error) {
+	if fd <= 0 {
+		return nil, fmt.Errorf("-progress-fd must be a file descriptor above 0")
+	}
+
+	f := os.NewFile(uintptr(fd), "progress-fd")
+	if f == nil {
+		return nil, fmt.Errorf("-progress-fd %d is invalid", fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("-progress-fd %d isn't open: %s", fd, err)
+	}
+
+	return f, nil
+}
+
+// progressKey identifies a build across its events.
+func progressKey(platform Platform, pkg, goVersion string) string {
+	return platform.String() + " " + pkg + " " + goVersion
+}
+
+// Started writes the started event of a build. It is meant to be used as
+// Builder.OnStart.
+func (p *progressWriter) Started(opts *CompileOpts) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	p.started[progressKey(opts.Platform, opts.PackagePath, opts.GoVersion)] = now
+	p.write(progressEvent{
+		Event:     "started",
+		Time:      now,
+		Platform:  opts.Platform.String(),
+		Package:   opts.PackagePath,
+		GoVersion: opts.GoVersion,
+	})
+}
+
+// Finished writes the finished event of a build.
+func (p *progressWriter) Finished(r BuildResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := progressEvent{
+		Event:     "finished",
+		Time:      p.now(),
+		Platform:  r.Platform.String(),
+		Package:   r.Package,
+		GoVersion: r.GoVersion,
+		Status:    string(r.status()),
+	}
+	key := progressKey(r.Platform, r.Package, r.GoVersion)
+	if start, ok := p.started[key]; ok {
+		e.DurationMs = e.Time.Sub(start).Nanoseconds() / int64(time.Millisecond)
+		delete(p.started, key)
+	}
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+	}
+
+	p.write(e)
+}
+
+// Err returns the first error writing an event, if any.
+func (p *progressWriter) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *progressWriter) write(e progressEvent) {
+	if p.err != nil {
+		return
+	}
+
+	p.err = p.enc.Encode(e)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgressWriter(&buf)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	opts := &CompileOpts{
+		PackagePath: "foo",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		GoVersion:   "go1.21.4",
+	}
+	p.Started(opts)
+	now = now.Add(1500 * time.Millisecond)
+	p.Finished(BuildResult{
+		Platform:  opts.Platform,
+		Package:   "foo",
+		GoVersion: "go1.21.4",
+		Status:    StatusFailed,
+		Err:       errors.New("exit status 2"),
+	})
+	p.Finished(BuildResult{
+		Platform: Platform{OS: "windows", Arch: "amd64"},
+		Package:  "foo",
+		Status:   StatusSkipped,
+	})
+	if err := p.Err(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	var events []progressEvent
+	for _, line := range lines {
+		var e progressEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		events = append(events, e)
+	}
+
+	if events[0].Event != "started" || events[0].Platform != "linux/amd64" || events[0].Status != "" {
+		t.Fatalf("bad: %#v", events[0])
+	}
+	if events[1].Event != "finished" || events[1].Status != "failed" ||
+		events[1].DurationMs != 1500 || events[1].Error != "exit status 2" {
+		t.Fatalf("bad: %#v", events[1])
+	}
+	if events[2].Status != "skipped" || events[2].DurationMs != 0 {
+		t.Fatalf("bad: %#v", events[2])
+	}
+}
+
+func TestOpenProgressFD(t *testing.T) {
+	if _, err := openProgressFD(0); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := openProgressFD(1000); err == nil {
+		t.Fatal("should error")
+	}
+}
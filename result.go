@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BuildStatus is how a build ended. Its values are part of the JSON
+// summary, so they must not change.
+type BuildStatus string
+
+const (
+	// StatusSuccess is a build that was built successfully.
+	StatusSuccess BuildStatus = "success"
+
+	// StatusFailed is a build that failed, such as with a compile error.
+	StatusFailed BuildStatus = "failed"
+
+	// StatusCancelled is a build that was stopped, or never started,
+	// because gox was interrupted.
+	StatusCancelled BuildStatus = "cancelled"
+
+	// StatusTimedOut is a build that was stopped because it took longer
+	// than -build-timeout.
+	StatusTimedOut BuildStatus = "timed_out"
+
+	// StatusSkipped is a build that wasn't attempted, such as the other
+	// platforms when the host build of -host-first fails.
+	StatusSkipped BuildStatus = "skipped"
+
+	// StatusCached is a build reused from an earlier run, with -resume.
+	StatusCached BuildStatus = "cached"
+)
+
+// BuildResult is the outcome of building a single package for a
+// single platform.
+type BuildResult struct {
+	Platform Platform
+	Package  string
+
+	// GoVersion is the version of Go that did the build.
+	GoVersion string
+
+	// Output is the path to the compiled binary.
+	Output string
+
+	// Artifacts are the final files produced for this build. This is
+	// the compiled binary unless a later step (packaging, compression,
+	// etc.) superseded it with something else.
+	Artifacts []string
+
+	// Size is the size in bytes of the output, after any post-processing.
+	Size int64
+
+	// Cover is true if the binary is coverage-instrumented.
+	Cover bool
+
+	// Status is how the build ended.
+	Status BuildStatus
+
+	// Err is non-nil if the build failed.
+	Err error
+}
+
+// status returns the Status of the result, falling back to one based on
+// Err for results that don't have it set.
+func (r *BuildResult) status() BuildStatus {
+	switch {
+	case r.Status != "":
+		return r.Status
+	case r.Err != nil:
+		return StatusFailed
+	default:
+		return StatusSuccess
+	}
+}
+
+// checkBuildCount verifies that exactly expected builds of results
+// succeeded, counting the ones reused with -resume, so that a matrix that
+// shrank without anyone noticing fails the run. The error lists the
+// builds that did succeed.
+func checkBuildCount(expected int, results []BuildResult) error {
+	var succeeded []string
+	for _, r := range results {
+		switch r.status() {
+		case StatusSuccess, StatusCached:
+			succeeded = append(succeeded, fmt.Sprintf("%s (%s)", r.Platform.String(), r.Package))
+		}
+	}
+	if len(succeeded) == expected {
+		return nil
+	}
+
+	sort.Strings(succeeded)
+	msg := fmt.Sprintf("expected %d successful builds, but there were %d", expected, len(succeeded))
+	if len(succeeded) > 0 {
+		msg += ":\n    " + strings.Join(succeeded, "\n    ")
+	}
+	return fmt.Errorf("%s", msg)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckBuildCount(t *testing.T) {
+	results := []BuildResult{
+		{Platform: Platform{OS: "linux", Arch: "amd64"}, Package: "foo", Status: StatusSuccess},
+		{Platform: Platform{OS: "darwin", Arch: "arm64"}, Package: "foo", Status: StatusCached},
+		{Platform: Platform{OS: "windows", Arch: "amd64"}, Package: "foo", Err: errors.New("failed")},
+		{Platform: Platform{OS: "plan9", Arch: "386"}, Package: "foo", Status: StatusSkipped},
+	}
+
+	if err := checkBuildCount(2, results); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := checkBuildCount(3, results)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	for _, s := range []string{"expected 3", "there were 2", "darwin/arm64 (foo)", "linux/amd64 (foo)"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+	if strings.Contains(err.Error(), "windows") {
+		t.Fatalf("bad: %s", err)
+	}
+}
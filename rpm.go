@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Tags and types of RPM headers, from rpmtag.h of rpm.
+const (
+	rpmTagHeaderSignatures = 62
+	rpmTagHeaderImmutable  = 63
+	rpmTagHeaderI18NTable  = 100
+
+	rpmSigTagSHA1        = 269
+	rpmSigTagSHA256      = 273
+	rpmSigTagSize        = 1000
+	rpmSigTagMD5         = 1004
+	rpmSigTagPayloadSize = 1007
+
+	rpmTagName              = 1000
+	rpmTagVersion           = 1001
+	rpmTagRelease           = 1002
+	rpmTagSummary           = 1004
+	rpmTagDescription       = 1005
+	rpmTagBuildTime         = 1006
+	rpmTagSize              = 1009
+	rpmTagLicense           = 1014
+	rpmTagPackager          = 1015
+	rpmTagGroup             = 1016
+	rpmTagOS                = 1021
+	rpmTagArch              = 1022
+	rpmTagFileSizes         = 1028
+	rpmTagFileModes         = 1030
+	rpmTagFileRdevs         = 1033
+	rpmTagFileMtimes        = 1034
+	rpmTagFileDigests       = 1035
+	rpmTagFileLinkTos       = 1036
+	rpmTagFileFlags         = 1037
+	rpmTagFileUserName      = 1039
+	rpmTagFileGroupName     = 1040
+	rpmTagSourceRPM         = 1044
+	rpmTagFileVerifyFlags   = 1045
+	rpmTagProvideName       = 1047
+	rpmTagRequireFlags      = 1048
+	rpmTagRequireName       = 1049
+	rpmTagRequireVersion    = 1050
+	rpmTagFileDevices       = 1095
+	rpmTagFileInodes        = 1096
+	rpmTagFileLangs         = 1097
+	rpmTagProvideFlags      = 1112
+	rpmTagProvideVersion    = 1113
+	rpmTagDirIndexes        = 1116
+	rpmTagBaseNames         = 1117
+	rpmTagDirNames          = 1118
+	rpmTagPayloadFormat     = 1124
+	rpmTagPayloadCompressor = 1125
+	rpmTagPayloadFlags      = 1126
+	rpmTagFileDigestAlgo    = 5011
+	rpmTagPayloadDigest     = 5092
+	rpmTagPayloadDigestAlgo = 5093
+
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeBin         = 7
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+
+	rpmSenseLess   = 1 << 1
+	rpmSenseEqual  = 1 << 3
+	rpmSenseRPMLib = 1 << 24
+
+	rpmDigestAlgoSHA256 = 8
+)
+
+// rpmHeaderMagic starts every header of an RPM package.
+var rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0}
+
+// writeRPM writes p as an RPM package to path. A .rpm is a lead, a
+// signature header with the digests of the rest, the header describing
+// the package and a gzipped cpio archive of the files to install.
+func writeRPM(path string, p linuxPackage) error {
+	binary, err := ioutil.ReadFile(p.Binary)
+	if err != nil {
+		return err
+	}
+
+	const release = "1"
+	arch := p.Platform.RPMArch()
+	nvr := fmt.Sprintf("%s-%s-%s", p.Name, p.Version, release)
+	mtime := int32(p.ModTime.Unix())
+
+	cpio := rpmCpio("./usr/bin/"+p.Name, p.binaryMode(), binary, mtime)
+	var payload bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&payload, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := gz.Write(cpio); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	h := &rpmHeader{}
+	h.addStrings(rpmTagHeaderI18NTable, "C")
+	h.addString(rpmTagName, p.Name)
+	h.addString(rpmTagVersion, p.Version)
+	h.addString(rpmTagRelease, release)
+	h.addI18NString(rpmTagSummary, strings.SplitN(strings.TrimSpace(p.Description), "\n", 2)[0])
+	h.addI18NString(rpmTagDescription, p.Description)
+	h.addInt32s(rpmTagBuildTime, mtime)
+	h.addInt32s(rpmTagSize, int32(len(binary)))
+	h.addString(rpmTagLicense, "Unspecified")
+	if p.Maintainer != "" {
+		h.addString(rpmTagPackager, p.Maintainer)
+	}
+	h.addI18NString(rpmTagGroup, "Unspecified")
+	h.addString(rpmTagOS, "linux")
+	h.addString(rpmTagArch, arch)
+	h.addInt32s(rpmTagFileSizes, int32(len(binary)))
+	h.addInt16s(rpmTagFileModes, uint16(0100000|p.binaryMode().Perm()))
+	h.addInt16s(rpmTagFileRdevs, 0)
+	h.addInt32s(rpmTagFileMtimes, mtime)
+	h.addStrings(rpmTagFileDigests, fmt.Sprintf("%x", sha256.Sum256(binary)))
+	h.addStrings(rpmTagFileLinkTos, "")
+	h.addInt32s(rpmTagFileFlags, 0)
+	h.addStrings(rpmTagFileUserName, "root")
+	h.addStrings(rpmTagFileGroupName, "root")
+	h.addString(rpmTagSourceRPM, nvr+".src.rpm")
+	h.addInt32s(rpmTagFileVerifyFlags, -1)
+	h.addStrings(rpmTagProvideName, p.Name, fmt.Sprintf("%s(%s)", p.Name, arch))
+	h.addInt32s(rpmTagProvideFlags, rpmSenseEqual, rpmSenseEqual)
+	h.addStrings(rpmTagProvideVersion, p.Version+"-"+release, p.Version+"-"+release)
+	h.addStrings(rpmTagRequireName, "rpmlib(CompressedFileNames)", "rpmlib(PayloadFilesHavePrefix)")
+	h.addInt32s(rpmTagRequireFlags,
+		rpmSenseLess|rpmSenseEqual|rpmSenseRPMLib, rpmSenseLess|rpmSenseEqual|rpmSenseRPMLib)
+	h.addStrings(rpmTagRequireVersion, "3.0.4-1", "4.0-1")
+	h.addInt32s(rpmTagFileDevices, 1)
+	h.addInt32s(rpmTagFileInodes, 1)
+	h.addStrings(rpmTagFileLangs, "")
+	h.addInt32s(rpmTagDirIndexes, 0)
+	h.addStrings(rpmTagBaseNames, p.Name)
+	h.addStrings(rpmTagDirNames, "/usr/bin/")
+	h.addString(rpmTagPayloadFormat, "cpio")
+	h.addString(rpmTagPayloadCompressor, "gzip")
+	h.addString(rpmTagPayloadFlags, "9")
+	h.addInt32s(rpmTagFileDigestAlgo, rpmDigestAlgoSHA256)
+	h.addStrings(rpmTagPayloadDigest, fmt.Sprintf("%x", sha256.Sum256(payload.Bytes())))
+	h.addInt32s(rpmTagPayloadDigestAlgo, rpmDigestAlgoSHA256)
+	header := h.Bytes(rpmTagHeaderImmutable)
+
+	headerAndPayload := append(append([]byte{}, header...), payload.Bytes()...)
+	md5sum := md5.Sum(headerAndPayload)
+	sig := &rpmHeader{}
+	sig.addString(rpmSigTagSHA1, fmt.Sprintf("%x", sha1.Sum(header)))
+	sig.addString(rpmSigTagSHA256, fmt.Sprintf("%x", sha256.Sum256(header)))
+	sig.addInt32s(rpmSigTagSize, int32(len(headerAndPayload)))
+	sig.add(rpmSigTagMD5, rpmTypeBin, int32(len(md5sum)), md5sum[:])
+	sig.addInt32s(rpmSigTagPayloadSize, int32(len(cpio)))
+	signature := sig.Bytes(rpmTagHeaderSignatures)
+
+	var buf bytes.Buffer
+	buf.Write(rpmLead(nvr))
+	buf.Write(signature)
+	// The signature header is padded to a multiple of 8 bytes
+	buf.Write(make([]byte, (8-len(signature)%8)%8))
+	buf.Write(headerAndPayload)
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// rpmLead returns the lead of a binary RPM package. The lead is only
+// kept for compatibility; rpm reads everything from the headers.
+func rpmLead(name string) []byte {
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	// Type 0 is a binary package, and the arch number is unused
+	copy(lead[10:75], name)
+	// OS 1 is linux, and signature type 5 is a signature header
+	binary.BigEndian.PutUint16(lead[76:], 1)
+	binary.BigEndian.PutUint16(lead[78:], 5)
+
+	return lead
+}
+
+// rpmCpio returns a cpio archive, in the "newc" format, of the single
+// regular file name with the permissions of mode.
+func rpmCpio(name string, mode os.FileMode, data []byte, mtime int32) []byte {
+	var buf bytes.Buffer
+	entry := func(name string, mode, nlink, ino int, data []byte) {
+		fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			ino, mode, 0, 0, nlink, mtime, len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name)
+		buf.WriteByte(0)
+		buf.Write(make([]byte, (4-buf.Len()%4)%4))
+		buf.Write(data)
+		buf.Write(make([]byte, (4-buf.Len()%4)%4))
+	}
+
+	entry(name, 0100000|int(mode.Perm()), 1, 1, data)
+	entry("TRAILER!!!", 0, 1, 0, nil)
+
+	return buf.Bytes()
+}
+
+// rpmHeader builds a header of an RPM package.
+type rpmHeader struct {
+	entries []rpmHeaderEntry
+}
+
+type rpmHeaderEntry struct {
+	tag, typ, count int32
+	data            []byte
+}
+
+func (h *rpmHeader) add(tag, typ, count int32, data []byte) {
+	h.entries = append(h.entries, rpmHeaderEntry{tag: tag, typ: typ, count: count, data: data})
+}
+
+func (h *rpmHeader) addString(tag int32, s string) {
+	h.add(tag, rpmTypeString, 1, append([]byte(s), 0))
+}
+
+func (h *rpmHeader) addI18NString(tag int32, s string) {
+	h.add(tag, rpmTypeI18NString, 1, append([]byte(s), 0))
+}
+
+func (h *rpmHeader) addStrings(tag int32, ss ...string) {
+	var data []byte
+	for _, s := range ss {
+		data = append(append(data, s...), 0)
+	}
+	h.add(tag, rpmTypeStringArray, int32(len(ss)), data)
+}
+
+func (h *rpmHeader) addInt16s(tag int32, vs ...uint16) {
+	data := make([]byte, 2*len(vs))
+	for i, v := range vs {
+		binary.BigEndian.PutUint16(data[2*i:], v)
+	}
+	h.add(tag, rpmTypeInt16, int32(len(vs)), data)
+}
+
+func (h *rpmHeader) addInt32s(tag int32, vs ...int32) {
+	data := make([]byte, 4*len(vs))
+	for i, v := range vs {
+		binary.BigEndian.PutUint32(data[4*i:], uint32(v))
+	}
+	h.add(tag, rpmTypeInt32, int32(len(vs)), data)
+}
+
+// Bytes returns the header, with its entries sorted by tag and made into
+// an immutable region with the given tag.
+func (h *rpmHeader) Bytes(regionTag int32) []byte {
+	entries := append([]rpmHeaderEntry{}, h.entries...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].tag < entries[j].tag
+	})
+
+	var index, store bytes.Buffer
+	writeIndex := func(tag, typ, offset, count int32) {
+		for _, v := range []int32{tag, typ, offset, count} {
+			binary.Write(&index, binary.BigEndian, v)
+		}
+	}
+
+	n := int32(len(entries) + 1)
+	for _, e := range entries {
+		align := 1
+		switch e.typ {
+		case rpmTypeInt16:
+			align = 2
+		case rpmTypeInt32:
+			align = 4
+		}
+		store.Write(make([]byte, (align-store.Len()%align)%align))
+
+		writeIndex(e.tag, e.typ, int32(store.Len()), e.count)
+		store.Write(e.data)
+	}
+
+	// The region is the first entry of the index, pointing to a trailer
+	// at the end of the store that holds its negated size
+	var region bytes.Buffer
+	regionOffset := int32(store.Len())
+	for _, v := range []int32{regionTag, rpmTypeBin, -n * 16, 16} {
+		binary.Write(&store, binary.BigEndian, v)
+	}
+	for _, v := range []int32{regionTag, rpmTypeBin, regionOffset, 16} {
+		binary.Write(&region, binary.BigEndian, v)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(rpmHeaderMagic)
+	binary.Write(&buf, binary.BigEndian, n)
+	binary.Write(&buf, binary.BigEndian, int32(store.Len()))
+	buf.Write(region.Bytes())
+	buf.Write(index.Bytes())
+	buf.Write(store.Bytes())
+
+	return buf.Bytes()
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRPM(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	binaryPath := filepath.Join(td, "app")
+	if err := ioutil.WriteFile(binaryPath, []byte("binary"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := linuxPackage{
+		Name:        "app",
+		Version:     "1.2.0",
+		Description: "An app",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		Mode:        0750,
+		Binary:      binaryPath,
+		ModTime:     time.Unix(1700000000, 0),
+	}
+	path := filepath.Join(td, "app.rpm")
+	if err := writeRPM(path, p); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.HasPrefix(data, []byte{0xed, 0xab, 0xee, 0xdb}) {
+		t.Fatalf("bad lead: %x", data[:4])
+	}
+
+	sig, n := readRPMHeader(t, data[96:])
+	rest := data[96+n+(8-n%8)%8:]
+	header, n := readRPMHeader(t, rest)
+	payload := rest[n:]
+
+	if sig[rpmSigTagSHA256] != fmt.Sprintf("%x", sha256.Sum256(rest[:n])) {
+		t.Fatalf("bad header digest: %s", sig[rpmSigTagSHA256])
+	}
+	if header[rpmTagPayloadDigest] != fmt.Sprintf("%x", sha256.Sum256(payload)) {
+		t.Fatalf("bad payload digest: %s", header[rpmTagPayloadDigest])
+	}
+	for tag, expected := range map[int32]string{
+		rpmTagName:      "app",
+		rpmTagVersion:   "1.2.0",
+		rpmTagArch:      "x86_64",
+		rpmTagBaseNames: "app",
+		rpmTagDirNames:  "/usr/bin/",
+		rpmTagSourceRPM: "app-1.2.0-1.src.rpm",
+	} {
+		if header[tag] != expected {
+			t.Fatalf("tag %d: bad: %q", tag, header[tag])
+		}
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cpio, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.HasPrefix(cpio, []byte("070701")) ||
+		!bytes.Contains(cpio, []byte("./usr/bin/app\x00")) ||
+		!bytes.Contains(cpio, []byte("binary")) ||
+		!bytes.Contains(cpio, []byte("TRAILER!!!")) {
+		t.Fatalf("bad payload: %q", cpio)
+	}
+
+	// The mode of the first entry, a regular file with p.Mode
+	if mode := string(cpio[14:22]); mode != fmt.Sprintf("%08X", 0100750) {
+		t.Fatalf("bad mode: %s", mode)
+	}
+}
+
+// writeTestRPM writes an rpm of a small binary to dir, for the tests
+// that check it with the rpm tools.
+func writeTestRPM(t *testing.T, dir string) string {
+	binaryPath := filepath.Join(dir, "app")
+	if err := ioutil.WriteFile(binaryPath, []byte("binary"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := filepath.Join(dir, "app-1.2.0-1.x86_64.rpm")
+	err := writeRPM(path, linuxPackage{
+		Name:        "app",
+		Version:     "1.2.0",
+		Maintainer:  "Jane Doe <jane@example.com>",
+		Description: "An app\n\nThat does things.",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		Binary:      binaryPath,
+		ModTime:     time.Unix(1700000000, 0),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return path
+}
+
+// TestWriteRPM_rpm checks the lead, signature and header with rpm itself,
+// as opposed to the parser of these tests.
+func TestWriteRPM_rpm(t *testing.T) {
+	if _, err := exec.LookPath("rpm"); err != nil {
+		t.Skip("rpm isn't on the PATH")
+	}
+
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := writeTestRPM(t, td)
+	rpm := func(args ...string) string {
+		args = append([]string{"--dbpath", td}, args...)
+		out, err := exec.Command("rpm", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("rpm %s: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return string(out)
+	}
+
+	// The package isn't signed, but the digests must check out
+	rpm("-K", "--nosignature", path)
+
+	info := rpm("-qip", path)
+	for _, field := range []string{
+		`Name\s*: app`,
+		`Version\s*: 1\.2\.0`,
+		`Release\s*: 1`,
+		`Architecture\s*: x86_64`,
+		`Packager\s*: Jane Doe <jane@example.com>`,
+		`Summary\s*: An app`,
+	} {
+		if !regexp.MustCompile(field).MatchString(info) {
+			t.Fatalf("missing %s:\n%s", field, info)
+		}
+	}
+
+	files := rpm("-qlvp", path)
+	if !regexp.MustCompile(`-rwxr-xr-x .* /usr/bin/app`).MatchString(files) {
+		t.Fatalf("bad files:\n%s", files)
+	}
+}
+
+func TestWriteRPM_rpm2cpio(t *testing.T) {
+	if _, err := exec.LookPath("rpm2cpio"); err != nil {
+		t.Skip("rpm2cpio isn't on the PATH")
+	}
+
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	out, err := exec.Command("rpm2cpio", writeTestRPM(t, td)).Output()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.HasPrefix(out, []byte("070701")) || !bytes.Contains(out, []byte("./usr/bin/app")) {
+		t.Fatalf("bad payload: %q", out)
+	}
+}
+
+// readRPMHeader reads the header at the start of data, returning the
+// first string of each string entry and the size of the header.
+func readRPMHeader(t *testing.T, data []byte) (map[int32]string, int) {
+	if !bytes.HasPrefix(data, rpmHeaderMagic) {
+		t.Fatalf("bad header magic: %x", data[:8])
+	}
+
+	n := int(binary.BigEndian.Uint32(data[8:]))
+	size := int(binary.BigEndian.Uint32(data[12:]))
+	store := data[16+16*n : 16+16*n+size]
+
+	result := make(map[int32]string)
+	for i := 0; i < n; i++ {
+		entry := data[16+16*i:]
+		tag := int32(binary.BigEndian.Uint32(entry))
+		typ := int32(binary.BigEndian.Uint32(entry[4:]))
+		offset := int32(binary.BigEndian.Uint32(entry[8:]))
+
+		switch typ {
+		case rpmTypeString, rpmTypeStringArray, rpmTypeI18NString:
+			s := string(store[offset:])
+			result[tag] = s[:strings.IndexByte(s, 0)]
+		case rpmTypeBin:
+			if i == 0 && -int32(binary.BigEndian.Uint32(store[offset+8:])) != int32(16*n) {
+				t.Fatalf("bad region trailer")
+			}
+		}
+	}
+
+	return result, 16 + 16*n + size
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// sizeDelta is the change in size of the output of a build between a
+// baseline manifest and this run.
+type sizeDelta struct {
+	Platform  string
+	Package   string
+	GoVersion string
+
+	// Old is the size in the baseline, or -1 if the build is new.
+	Old int64
+	New int64
+}
+
+// Percent returns the change in size as a percentage of the old size.
+func (d sizeDelta) Percent() float64 {
+	if d.Old <= 0 {
+		return 0
+	}
+
+	return 100 * float64(d.New-d.Old) / float64(d.Old)
+}
+
+// compareSizes returns the size deltas of the successful builds of
+// current against the baseline, in the order of current. A build is
+// compared with the baseline build of the same platform, package and Go
+// version or, if the baseline only has one Go version for the platform
+// and package, with that one, such as when comparing with the builds of
+// an older Go.
+func compareSizes(baseline, current *Summary) []sizeDelta {
+	old := make(map[string]int64)
+	versions := make(map[string][]string)
+	for _, b := range baseline.Builds {
+		if b.Error == "" && b.Size > 0 {
+			key := b.Platform + " " + b.Package
+			old[key+" "+b.GoVersion] = b.Size
+			versions[key] = append(versions[key], b.GoVersion)
+		}
+	}
+
+	var deltas []sizeDelta
+	for _, b := range current.Builds {
+		if b.Error != "" || b.Size == 0 {
+			continue
+		}
+
+		d := sizeDelta{Platform: b.Platform, Package: b.Package, GoVersion: b.GoVersion, Old: -1, New: b.Size}
+		key := b.Platform + " " + b.Package
+		if size, ok := old[key+" "+b.GoVersion]; ok {
+			d.Old = size
+		} else if vs := versions[key]; len(vs) == 1 {
+			d.Old = old[key+" "+vs[0]]
+		}
+		deltas = append(deltas, d)
+	}
+
+	return deltas
+}
+
+// printSizeDeltas writes the size deltas in a human readable form.
+func printSizeDeltas(w io.Writer, baseline string, deltas []sizeDelta) {
+	fmt.Fprintf(w, "\nSize changes since %s:\n", baseline)
+	for _, d := range deltas {
+		if d.Old < 0 {
+			fmt.Fprintf(w, "  %15s: %s: %d bytes (new)\n", d.Platform, d.build(), d.New)
+			continue
+		}
+
+		fmt.Fprintf(w, "  %15s: %s: %d -> %d bytes (%+d, %+.2f%%)\n",
+			d.Platform, d.build(), d.Old, d.New, d.New-d.Old, d.Percent())
+	}
+}
+
+// build returns the package of the delta, along with its Go version if
+// it is known.
+func (d sizeDelta) build() string {
+	if d.GoVersion == "" {
+		return d.Package
+	}
+
+	return fmt.Sprintf("%s (%s)", d.Package, d.GoVersion)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareSizes(t *testing.T) {
+	baseline := &Summary{
+		Builds: []SummaryBuild{
+			{Platform: "linux/amd64", Package: "foo", Size: 1000},
+			{Platform: "linux/arm", Package: "foo", Error: "failed"},
+			{Platform: "windows/amd64", Package: "foo", Size: 2000},
+		},
+	}
+	current := &Summary{
+		Builds: []SummaryBuild{
+			{Platform: "linux/amd64", Package: "foo", Size: 1100},
+			{Platform: "linux/arm", Package: "foo", Size: 900},
+			{Platform: "windows/amd64", Package: "foo", Error: "failed"},
+		},
+	}
+
+	deltas := compareSizes(baseline, current)
+	expected := []sizeDelta{
+		{Platform: "linux/amd64", Package: "foo", Old: 1000, New: 1100},
+		{Platform: "linux/arm", Package: "foo", Old: -1, New: 900},
+	}
+	if !reflect.DeepEqual(deltas, expected) {
+		t.Fatalf("bad: %#v", deltas)
+	}
+	if p := deltas[0].Percent(); p != 10 {
+		t.Fatalf("bad: %f", p)
+	}
+
+	var buf bytes.Buffer
+	printSizeDeltas(&buf, "old.json", deltas)
+	output := "\nSize changes since old.json:\n" +
+		"      linux/amd64: foo: 1000 -> 1100 bytes (+100, +10.00%)\n" +
+		"        linux/arm: foo: 900 bytes (new)\n"
+	if buf.String() != output {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
+
+func TestCompareSizes_goVersions(t *testing.T) {
+	baseline := &Summary{
+		Builds: []SummaryBuild{
+			{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.21.4", Size: 1000},
+			{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.22.1", Size: 2000},
+			{Platform: "linux/arm", Package: "foo", GoVersion: "go1.21.4", Size: 500},
+		},
+	}
+	current := &Summary{
+		Builds: []SummaryBuild{
+			{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.21.4", Size: 1100},
+			{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.22.1", Size: 1800},
+			{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.23.0", Size: 1900},
+			{Platform: "linux/arm", Package: "foo", GoVersion: "go1.23.0", Size: 600},
+		},
+	}
+
+	// Each Go version is compared with its own build. A new Go version is
+	// compared with the baseline only if it has a single Go version.
+	deltas := compareSizes(baseline, current)
+	expected := []sizeDelta{
+		{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.21.4", Old: 1000, New: 1100},
+		{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.22.1", Old: 2000, New: 1800},
+		{Platform: "linux/amd64", Package: "foo", GoVersion: "go1.23.0", Old: -1, New: 1900},
+		{Platform: "linux/arm", Package: "foo", GoVersion: "go1.23.0", Old: 500, New: 600},
+	}
+	if !reflect.DeepEqual(deltas, expected) {
+		t.Fatalf("bad: %#v", deltas)
+	}
+
+	var buf bytes.Buffer
+	printSizeDeltas(&buf, "old.json", deltas[:2])
+	output := "\nSize changes since old.json:\n" +
+		"      linux/amd64: foo (go1.21.4): 1000 -> 1100 bytes (+100, +10.00%)\n" +
+		"      linux/amd64: foo (go1.22.1): 2000 -> 1800 bytes (-200, -10.00%)\n"
+	if buf.String() != output {
+		t.Fatalf("bad: %q", buf.String())
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	s := &Summary{
+		GoVersion: "go1.21.4",
+		Success:   true,
+		Builds: []SummaryBuild{
+			{Platform: "linux/amd64", Package: "foo", Output: "/foo", Size: 1000},
+		},
+	}
+
+	path := filepath.Join(td, "manifest.json")
+	if err := writeManifest(path, s); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual, err := readManifest(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, s) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// runState records the builds of a run that produced their artifacts,
+// so that an interrupted run can be resumed without rebuilding them. It
+// is saved after every successful build.
+type runState struct {
+	Builds map[string]stateBuild `json:"builds"`
+
+	path string
+	lock sync.Mutex
+}
+
+// stateBuild is a successful build in the run state.
+type stateBuild struct {
+	Output string `json:"output"`
+
+	// Artifacts are the artifacts of the build, in the order of the
+	// result.
+	Artifacts []stateArtifact `json:"artifacts"`
+}
+
+// stateArtifact is an artifact of a build and its SHA-256 checksum.
+type stateArtifact struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// newRunState returns an empty run state that is saved to path.
+func newRunState(path string) *runState {
+	return &runState{
+		Builds: make(map[string]stateBuild),
+		path:   path,
+	}
+}
+
+// loadRunState reads the run state saved to path. A missing file is an
+// empty state.
+func loadRunState(path string) (*runState, error) {
+	s := newRunState(path)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Builds == nil {
+		s.Builds = make(map[string]stateBuild)
+	}
+
+	return s, nil
+}
+
+func stateKey(p Platform, pkg, goVersion string) string {
+	return p.String() + " " + pkg + " " + goVersion
+}
+
+// Resume returns the result of a recorded build of opts if it can be
+// reused: it wrote to the same output, and all of its artifacts still
+// exist unchanged.
+func (s *runState) Resume(opts *CompileOpts) (BuildResult, bool) {
+	s.lock.Lock()
+	build, ok := s.Builds[stateKey(opts.Platform, opts.PackagePath, opts.GoVersion)]
+	s.lock.Unlock()
+	if !ok {
+		return BuildResult{}, false
+	}
+
+	output, err := opts.OutputPath()
+	if err != nil || output != build.Output {
+		return BuildResult{}, false
+	}
+
+	result := BuildResult{
+		Platform:  opts.Platform,
+		Package:   opts.PackagePath,
+		GoVersion: opts.GoVersion,
+		Output:    output,
+		Cover:     opts.Cover,
+		Status:    StatusCached,
+	}
+	for _, a := range build.Artifacts {
+		if actual, err := fileSHA256(a.Path); err != nil || actual != a.SHA256 {
+			return BuildResult{}, false
+		}
+
+		result.Artifacts = append(result.Artifacts, a.Path)
+	}
+	if info, err := os.Stat(output); err == nil {
+		result.Size = info.Size()
+	}
+
+	return result, true
+}
+
+// Record adds a successful build to the state and saves it.
+func (s *runState) Record(result BuildResult) error {
+	build := stateBuild{
+		Output:    result.Output,
+		Artifacts: make([]stateArtifact, 0, len(result.Artifacts)),
+	}
+	for _, path := range result.Artifacts {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+
+		build.Artifacts = append(build.Artifacts, stateArtifact{Path: path, SHA256: sum})
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Builds[stateKey(result.Platform, result.Package, result.GoVersion)] = build
+	return s.save()
+}
+
+// Relocate updates the recorded artifacts of the successful results to
+// where the results have them now, after -artifacts-dir moved them, and
+// saves the state. The checksums are kept, since moving a file doesn't
+// change it.
+func (s *runState) Relocate(results []BuildResult) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+
+		build, ok := s.Builds[stateKey(r.Platform, r.Package, r.GoVersion)]
+		if !ok || len(build.Artifacts) != len(r.Artifacts) {
+			continue
+		}
+		for i := range build.Artifacts {
+			build.Artifacts[i].Path = r.Artifacts[i]
+		}
+	}
+
+	return s.save()
+}
+
+// save writes the state atomically, so that an interrupt can't leave a
+// partial file behind. The lock must be held.
+func (s *runState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".gox-state")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// fileSHA256 returns the hex encoded SHA-256 checksum of a file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunState(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	opts := &CompileOpts{
+		PackagePath: "example.com/foo",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		OutputTpl:   filepath.Join(td, "{{.Dir}}_{{.OS}}_{{.Arch}}"),
+	}
+	output, err := opts.OutputPath()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(output, []byte("binary"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	statePath := filepath.Join(td, "state.json")
+	s := newRunState(statePath)
+	if _, ok := s.Resume(opts); ok {
+		t.Fatal("should not resume an unrecorded build")
+	}
+
+	result := BuildResult{
+		Platform:  opts.Platform,
+		Package:   opts.PackagePath,
+		Output:    output,
+		Artifacts: []string{output},
+		Size:      int64(len("binary")),
+	}
+	if err := s.Record(result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A fresh load sees the recorded build
+	s, err = loadRunState(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resumed, ok := s.Resume(opts)
+	if !ok {
+		t.Fatal("should resume")
+	}
+	expected := result
+	expected.Status = StatusCached
+	if !reflect.DeepEqual(resumed, expected) {
+		t.Fatalf("bad: %#v", resumed)
+	}
+
+	// Another output template is another build
+	other := *opts
+	other.OutputTpl = filepath.Join(td, "other")
+	if _, ok := s.Resume(&other); ok {
+		t.Fatal("should not resume a different output")
+	}
+
+	// A changed artifact must be rebuilt
+	if err := ioutil.WriteFile(output, []byte("changed"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := s.Resume(opts); ok {
+		t.Fatal("should not resume a changed artifact")
+	}
+
+	// So must a missing one
+	os.Remove(output)
+	if _, ok := s.Resume(opts); ok {
+		t.Fatal("should not resume a missing artifact")
+	}
+}
+
+func TestRunState_artifacts(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	opts := &CompileOpts{
+		PackagePath: "example.com/foo",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		OutputTpl:   filepath.Join(td, "{{.Dir}}"),
+	}
+	output, err := opts.OutputPath()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Artifacts keep their order, which a map wouldn't
+	var artifacts []string
+	for _, name := range []string{"foo", "foo.tar.gz", "foo.deb", "foo.rpm", "foo.sig"} {
+		path := filepath.Join(td, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		artifacts = append(artifacts, path)
+	}
+	result := BuildResult{
+		Platform:  opts.Platform,
+		Package:   opts.PackagePath,
+		Output:    output,
+		Artifacts: artifacts,
+	}
+
+	s := newRunState(filepath.Join(td, "state.json"))
+	if err := s.Record(result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Moving the artifacts to -artifacts-dir is recorded, so that the
+	// build is resumed from there
+	results := []BuildResult{result}
+	results[0].Artifacts = append([]string(nil), artifacts...)
+	if err := collectArtifacts(filepath.Join(td, "dist"), true, results); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.Relocate(results); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	s, err = loadRunState(s.path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resumed, ok := s.Resume(opts)
+	if !ok {
+		t.Fatal("should resume")
+	}
+	if !reflect.DeepEqual(resumed.Artifacts, results[0].Artifacts) {
+		t.Fatalf("bad: %#v", resumed.Artifacts)
+	}
+}
+
+func TestLoadRunState_missing(t *testing.T) {
+	s, err := loadRunState(filepath.Join(os.TempDir(), "gox-does-not-exist.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(s.Builds) != 0 {
+		t.Fatalf("bad: %#v", s.Builds)
+	}
+}
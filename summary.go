@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sort"
+)
+
+// Summary is a machine readable summary of a run, for consumption by
+// other tools. Its JSON form is part of the public interface of gox, so
+// fields may be added but not changed or removed.
+type Summary struct {
+	GoVersion string         `json:"go_version"`
+	Channel   string         `json:"channel,omitempty"`
+	Success   bool           `json:"success"`
+	Builds    []SummaryBuild `json:"builds"`
+
+	// Artifacts are the files of the run as a whole, such as checksum
+	// files, as opposed to those of a single build.
+	Artifacts []string `json:"artifacts,omitempty"`
+}
+
+// SummaryBuild is the summary of a single BuildResult.
+type SummaryBuild struct {
+	Platform  string   `json:"platform"`
+	Package   string   `json:"package"`
+	GoVersion string   `json:"go_version,omitempty"`
+	Status    string   `json:"status"`
+	Output    string   `json:"output,omitempty"`
+	Artifacts []string `json:"artifacts,omitempty"`
+	Size      int64    `json:"size,omitempty"`
+	Cover     bool     `json:"cover,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// NewSummary summarizes the results of a run. Success is true if every
+// build succeeded or was reused; callers may still clear it for other
+// failures. The builds are sorted by Go version, platform and package.
+func NewSummary(goVersion string, results []BuildResult) *Summary {
+	s := &Summary{
+		GoVersion: goVersion,
+		Success:   true,
+		Builds:    make([]SummaryBuild, 0, len(results)),
+	}
+
+	for _, r := range results {
+		b := SummaryBuild{
+			Platform:  r.Platform.String(),
+			Package:   r.Package,
+			GoVersion: r.GoVersion,
+			Output:    r.Output,
+			Artifacts: r.Artifacts,
+			Size:      r.Size,
+			Cover:     r.Cover,
+			Status:    string(r.status()),
+		}
+		if r.Err != nil {
+			b.Error = r.Err.Error()
+		}
+		if status := r.status(); status != StatusSuccess && status != StatusCached {
+			s.Success = false
+		}
+
+		s.Builds = append(s.Builds, b)
+	}
+
+	sort.Slice(s.Builds, func(i, j int) bool {
+		if s.Builds[i].GoVersion != s.Builds[j].GoVersion {
+			return s.Builds[i].GoVersion < s.Builds[j].GoVersion
+		}
+		if s.Builds[i].Platform != s.Builds[j].Platform {
+			return s.Builds[i].Platform < s.Builds[j].Platform
+		}
+		return s.Builds[i].Package < s.Builds[j].Package
+	})
+
+	return s
+}
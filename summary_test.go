@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewSummary_status(t *testing.T) {
+	linux := Platform{OS: "linux", Arch: "amd64"}
+	windows := Platform{OS: "windows", Arch: "amd64"}
+
+	s := NewSummary("go1.21", []BuildResult{
+		{Platform: windows, Package: "foo", Status: StatusCached},
+		{Platform: linux, Package: "foo"},
+	})
+	if !s.Success || s.Builds[0].Status != "success" || s.Builds[1].Status != "cached" {
+		t.Fatalf("bad: %#v", s)
+	}
+
+	s = NewSummary("go1.21", []BuildResult{
+		{Platform: linux, Package: "foo", Err: errors.New("failed")},
+		{Platform: windows, Package: "foo", Status: StatusSkipped},
+	})
+	if s.Success || s.Builds[0].Status != "failed" || s.Builds[0].Error != "failed" ||
+		s.Builds[1].Status != "skipped" || s.Builds[1].Error != "" {
+		t.Fatalf("bad: %#v", s)
+	}
+}
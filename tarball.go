@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// tarballCompressor is a compression for the tarballs of -package=tgz.
+type tarballCompressor struct {
+	// Ext is the extension of the tarball, such as ".tar.gz".
+	Ext string
+
+	// Writer returns a writer compressing to w.
+	Writer func(w io.Writer) (io.WriteCloser, error)
+}
+
+// tarballCompressors are the compressions of -package-compression.
+var tarballCompressors = map[string]tarballCompressor{
+	"gzip": {".tar.gz", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	}},
+	"zstd": {".tar.zst", func(w io.Writer) (io.WriteCloser, error) {
+		// One encoder goroutine, so the output doesn't depend on the
+		// number of CPUs.
+		return zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.SpeedBestCompression),
+			zstd.WithEncoderConcurrency(1))
+	}},
+	"xz": {".tar.xz", func(w io.Writer) (io.WriteCloser, error) {
+		return xz.NewWriter(w)
+	}},
+}
+
+// tarballFileName names the tarball of p after its binary, without
+// any ".exe".
+func tarballFileName(p linuxPackage) string {
+	name := strings.TrimSuffix(filepath.Base(p.Binary), ".exe")
+	return name + tarballCompressors[p.Compression].Ext
+}
+
+// writeTarball writes the binary of p to path as a compressed tarball,
+// holding only the binary, owned by root and dated p.ModTime. The binary
+// has p.Mode if it is set, or else 0755.
+func writeTarball(path string, p linuxPackage) (err error) {
+	compressor, ok := tarballCompressors[p.Compression]
+	if !ok {
+		return fmt.Errorf("unknown compression %q", p.Compression)
+	}
+
+	binary, err := os.Open(p.Binary)
+	if err != nil {
+		return err
+	}
+	defer binary.Close()
+
+	info, err := binary.Stat()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	cw, err := compressor.Writer(f)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(cw)
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     filepath.Base(p.Binary),
+		Mode:     int64(p.binaryMode()),
+		Size:     info.Size(),
+		ModTime:  p.ModTime,
+		Uname:    "root",
+		Gname:    "root",
+		Format:   tar.FormatGNU,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, binary); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return cw.Close()
+}
+
+// parsePackageCompression checks the value of -package-compression,
+// defaulting to gzip.
+func parsePackageCompression(s string) (string, error) {
+	if s == "" {
+		return "gzip", nil
+	}
+	if _, ok := tarballCompressors[s]; !ok {
+		return "", fmt.Errorf("unknown package compression %q, it should be gzip, zstd or xz", s)
+	}
+
+	return s, nil
+}
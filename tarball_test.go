@@ -0,0 +1,90 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func TestWriteTarball(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	binary := filepath.Join(dir, "app_windows_amd64.exe")
+	if err := ioutil.WriteFile(binary, []byte("binary"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	readers := map[string]func(r io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		"xz":   func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) },
+	}
+	for compression, reader := range readers {
+		p := linuxPackage{
+			Binary:      binary,
+			Compression: compression,
+			ModTime:     time.Unix(1700000000, 0),
+		}
+
+		path := filepath.Join(dir, tarballFileName(p))
+		if err := writeTarball(path, p); err != nil {
+			t.Fatalf("%s: err: %s", compression, err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("%s: err: %s", compression, err)
+		}
+		r, err := reader(f)
+		if err != nil {
+			t.Fatalf("%s: err: %s", compression, err)
+		}
+		tr := tar.NewReader(r)
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("%s: err: %s", compression, err)
+		}
+		if hdr.Name != "app_windows_amd64.exe" || !hdr.ModTime.Equal(p.ModTime) {
+			t.Fatalf("%s: bad: %#v", compression, hdr)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("%s: err: %s", compression, err)
+		}
+		if string(data) != "binary" {
+			t.Fatalf("%s: bad: %q", compression, data)
+		}
+		f.Close()
+	}
+}
+
+func TestTarballFileName(t *testing.T) {
+	p := linuxPackage{Binary: "dist/app_windows_amd64.exe", Compression: "zstd"}
+	if actual := tarballFileName(p); actual != "app_windows_amd64.tar.zst" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestParsePackageCompression(t *testing.T) {
+	if actual, err := parsePackageCompression(""); err != nil || actual != "gzip" {
+		t.Fatalf("bad: %s %v", actual, err)
+	}
+	if actual, err := parsePackageCompression("xz"); err != nil || actual != "xz" {
+		t.Fatalf("bad: %s %v", actual, err)
+	}
+	if _, err := parsePackageCompression("bzip2"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// ValidateOptions checks the build options against every platform that
+// will be built and returns all of the invalid combinations found, so
+// that they can be reported together before anything is built. The
+// package and platform of opts are ignored.
+func ValidateOptions(opts *CompileOpts, platforms []Platform) []error {
+	var errs []error
+
+	switch opts.ModMode {
+	case "", "mod", "readonly", "vendor":
+	default:
+		errs = append(errs, fmt.Errorf(
+			"-mod=%s is not one of mod, readonly or vendor", opts.ModMode))
+	}
+
+	// The race detector needs cgo, which we can't turn on if it was
+	// explicitly turned off.
+	if opts.Race && os.Getenv("CGO_ENABLED") == "0" {
+		for _, p := range platforms {
+			if p.SupportsRace(opts.GoVersion) {
+				errs = append(errs, fmt.Errorf(
+					"%s: -race requires cgo, but CGO_ENABLED=0", p.String()))
+			}
+		}
+	}
+
+	switch opts.BuildVCS {
+	case "", "true", "false", "auto":
+	default:
+		errs = append(errs, fmt.Errorf(
+			"-buildvcs=%s is not one of true, false or auto", opts.BuildVCS))
+	}
+
+	// The channel ends up in file names
+	for _, c := range opts.Channel {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '.' || c == '_' || c == '-') {
+			errs = append(errs, fmt.Errorf(
+				"-channel=%s may only contain letters, digits and \"._-\"", opts.Channel))
+			break
+		}
+	}
+
+	switch opts.CoverMode {
+	case "", "set", "count", "atomic":
+	default:
+		errs = append(errs, fmt.Errorf(
+			"-covermode=%s is not one of set, count or atomic", opts.CoverMode))
+	}
+
+	if opts.Buildmode != "" {
+		if _, ok := buildModes[opts.Buildmode]; !ok {
+			errs = append(errs, fmt.Errorf(
+				"-buildmode=%s is not a known build mode", opts.Buildmode))
+			return errs
+		}
+
+		for _, p := range platforms {
+			if !buildModeSupported(opts.Buildmode, p) {
+				errs = append(errs, fmt.Errorf(
+					"%s: -buildmode=%s is not supported", p.String(), opts.Buildmode))
+				continue
+			}
+
+			if buildModes[opts.Buildmode] && p.String() != "wasip1/wasm" &&
+				!cgoEnabled(opts.Cgo, p) {
+				errs = append(errs, fmt.Errorf(
+					"%s: -buildmode=%s requires cgo, use -cgo or unset CGO_ENABLED=0",
+					p.String(), opts.Buildmode))
+			}
+		}
+	}
+
+	return errs
+}
+
+// buildModes are the known values of -buildmode, mapped to whether they
+// need cgo.
+var buildModes = map[string]bool{
+	"archive":   false,
+	"c-archive": true,
+	"c-shared":  true,
+	"default":   false,
+	"exe":       false,
+	"pie":       false,
+	"plugin":    true,
+	"shared":    true,
+}
+
+// buildModeSupported reports whether the build mode is supported on the
+// platform. This follows BuildModeSupported in Go's internal/platform
+// package for the gc compiler.
+func buildModeSupported(mode string, p Platform) bool {
+	platform := p.String()
+	switch mode {
+	case "archive", "default", "exe":
+		return true
+
+	case "c-archive":
+		switch p.OS {
+		case "aix", "darwin", "ios", "windows":
+			return true
+		case "linux":
+			switch p.Arch {
+			case "386", "amd64", "arm", "armbe", "arm64", "arm64be", "loong64",
+				"ppc64", "ppc64le", "riscv64", "s390x":
+				return true
+			}
+		case "freebsd":
+			return p.Arch == "amd64"
+		}
+		return false
+
+	case "c-shared":
+		switch platform {
+		case "linux/amd64", "linux/arm", "linux/arm64", "linux/loong64", "linux/386",
+			"linux/ppc64", "linux/ppc64le", "linux/riscv64", "linux/s390x",
+			"android/amd64", "android/arm", "android/arm64", "android/386",
+			"freebsd/amd64",
+			"darwin/amd64", "darwin/arm64",
+			"windows/amd64", "windows/386", "windows/arm64",
+			"wasip1/wasm":
+			return true
+		}
+		return false
+
+	case "pie":
+		switch platform {
+		case "linux/386", "linux/amd64", "linux/arm", "linux/arm64", "linux/loong64",
+			"linux/ppc64", "linux/ppc64le", "linux/riscv64", "linux/s390x",
+			"android/amd64", "android/arm", "android/arm64", "android/386",
+			"freebsd/amd64",
+			"darwin/amd64", "darwin/arm64",
+			"ios/amd64", "ios/arm64",
+			"aix/ppc64",
+			"openbsd/arm64",
+			"windows/386", "windows/amd64", "windows/arm64":
+			return true
+		}
+		return false
+
+	case "shared":
+		switch platform {
+		case "linux/386", "linux/amd64", "linux/arm", "linux/arm64",
+			"linux/ppc64", "linux/ppc64le", "linux/s390x":
+			return true
+		}
+		return false
+
+	case "plugin":
+		switch platform {
+		case "linux/amd64", "linux/arm", "linux/arm64", "linux/386", "linux/loong64",
+			"linux/riscv64", "linux/s390x", "linux/ppc64", "linux/ppc64le",
+			"android/amd64", "android/386",
+			"darwin/amd64", "darwin/arm64",
+			"freebsd/amd64":
+			return true
+		}
+		return false
+	}
+
+	return false
+}
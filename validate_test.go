@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateOptions(t *testing.T) {
+	os.Setenv("CGO_ENABLED", "0")
+	defer os.Unsetenv("CGO_ENABLED")
+
+	platforms := []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "windows", Arch: "amd64"},
+	}
+
+	cases := []struct {
+		Opts   CompileOpts
+		Errors []string
+	}{
+		{
+			CompileOpts{},
+			nil,
+		},
+		{
+			CompileOpts{Buildmode: "pie"},
+			nil,
+		},
+		{
+			CompileOpts{Buildmode: "bogus", ModMode: "bogus"},
+			[]string{"-mod=bogus", "-buildmode=bogus is not a known"},
+		},
+		{
+			CompileOpts{Race: true},
+			[]string{
+				"linux/amd64: -race requires cgo",
+				"windows/amd64: -race requires cgo",
+			},
+		},
+		{
+			CompileOpts{Cover: true, CoverMode: "atomic"},
+			nil,
+		},
+		{
+			CompileOpts{Cover: true, CoverMode: "bogus"},
+			[]string{"-covermode=bogus"},
+		},
+		{
+			CompileOpts{Channel: "beta-2"},
+			nil,
+		},
+		{
+			CompileOpts{Channel: "beta/2"},
+			[]string{"-channel=beta/2 may only contain"},
+		},
+		{
+			CompileOpts{Buildmode: "plugin", Cgo: true},
+			[]string{"windows/amd64: -buildmode=plugin is not supported"},
+		},
+		{
+			CompileOpts{Buildmode: "plugin"},
+			[]string{
+				"linux/amd64: -buildmode=plugin requires cgo",
+				"windows/amd64: -buildmode=plugin is not supported",
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		errs := ValidateOptions(&tc.Opts, platforms)
+		if len(errs) != len(tc.Errors) {
+			t.Fatalf("%d: bad: %v", i, errs)
+		}
+
+		for j, err := range errs {
+			if !strings.Contains(err.Error(), tc.Errors[j]) {
+				t.Fatalf("%d: bad: %s", i, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// VCSInfo is version control information about the source being built,
+// exposed to the output template as {{.Commit}} and {{.Tag}}.
+type VCSInfo struct {
+	Commit string
+	Tag    string
+}
+
+// GitVCSInfo reads the commit and tag of HEAD from git in the directory
+// dir. The tag is empty if HEAD isn't tagged. An error is returned if git
+// isn't installed or dir isn't a git repository; callers are expected to
+// carry on without the information in that case.
+func GitVCSInfo(dir string) (VCSInfo, error) {
+	var info VCSInfo
+	commit, err := execGit(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return info, err
+	}
+	info.Commit = commit
+
+	// Not being on a tag is normal, so any error here is ignored.
+	info.Tag, _ = execGit(dir, "describe", "--tags", "--exact-match", "HEAD")
+	return info, nil
+}
+
+// resolveVCSInfo returns the VCS information for the build. Values given
+// explicitly take precedence over git, and git is only consulted for the
+// values that are missing. If git can't provide them they are left empty,
+// with a warning in verbose mode.
+func resolveVCSInfo(dir string, explicit VCSInfo, verbose bool) VCSInfo {
+	if explicit.Commit != "" && explicit.Tag != "" {
+		return explicit
+	}
+
+	info, err := GitVCSInfo(dir)
+	if err != nil && verbose {
+		fmt.Printf("Unable to read commit and tag from git, they will be "+
+			"empty unless -commit and -tag are given: %s\n", err)
+	}
+
+	if explicit.Commit != "" {
+		info.Commit = explicit.Commit
+	}
+	if explicit.Tag != "" {
+		info.Tag = explicit.Tag
+	}
+
+	return info
+}
+
+func execGit(dir string, args ...string) (string, error) {
+	var stderr, stdout bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s\nStderr: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
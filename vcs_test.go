@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestResolveVCSInfo_noRepo(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Outside of a repository (or without git at all) nothing is known
+	info := resolveVCSInfo(td, VCSInfo{}, false)
+	if info != (VCSInfo{}) {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	// Explicit values are used regardless
+	info = resolveVCSInfo(td, VCSInfo{Commit: "abc"}, false)
+	if info != (VCSInfo{Commit: "abc"}) {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	explicit := VCSInfo{Commit: "abc", Tag: "v1.0.0"}
+	if info := resolveVCSInfo(td, explicit, false); info != explicit {
+		t.Fatalf("bad: %#v", info)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// warningList prints the warnings of a run and collects them, so that
+// they can be reported again or, with -strict, fail the run. It is safe
+// for concurrent use.
+type warningList struct {
+	// Stdout is where Printf prints, os.Stdout if nil. Output meant for
+	// other programs sends the warnings to stderr instead.
+	Stdout io.Writer
+
+	mu   sync.Mutex
+	list []string
+}
+
+// Printf prints a warning to Stdout and records it.
+func (l *warningList) Printf(format string, args ...interface{}) {
+	w := l.Stdout
+	if w == nil {
+		w = os.Stdout
+	}
+	l.Fprintf(w, format, args...)
+}
+
+// Fprintf prints a warning to w and records it.
+func (l *warningList) Fprintf(w io.Writer, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprint(w, msg)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list = append(l.list, strings.TrimSpace(strings.TrimPrefix(msg, "Warning: ")))
+}
+
+// Warnings returns a copy of the warnings recorded so far.
+func (l *warningList) Warnings() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.list...)
+}
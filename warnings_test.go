@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWarningList(t *testing.T) {
+	var l warningList
+	var buf bytes.Buffer
+	l.Fprintf(&buf, "Go compiler version %s does not support the -pgo flag\n", "go1.20")
+	l.Fprintf(&buf, "Warning: notifying %s failed: %s\n", "http://example.com", "timeout")
+
+	expected := "Go compiler version go1.20 does not support the -pgo flag\n" +
+		"Warning: notifying http://example.com failed: timeout\n"
+	if buf.String() != expected {
+		t.Fatalf("bad: %q", buf.String())
+	}
+
+	warnings := l.Warnings()
+	if !reflect.DeepEqual(warnings, []string{
+		"Go compiler version go1.20 does not support the -pgo flag",
+		"notifying http://example.com failed: timeout",
+	}) {
+		t.Fatalf("bad: %#v", warnings)
+	}
+}
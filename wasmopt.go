@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// wasmOptStep returns a post-build step that optimizes wasm modules in
+// place with Binaryen's wasm-opt, at the given optimization level such
+// as "Oz". Other platforms are left alone.
+func wasmOptStep(cmdName, level string) postBuildStep {
+	return func(opts *CompileOpts, result *BuildResult) error {
+		if opts.Platform.Arch != "wasm" {
+			return nil
+		}
+
+		return wasmOpt(cmdName, level, result.Output)
+	}
+}
+
+// wasmOpt optimizes the wasm module at path, replacing it with the
+// optimized one.
+func wasmOpt(cmdName, level, path string) error {
+	optimized := path + ".opt"
+	var output bytes.Buffer
+	cmd := exec.Command(cmdName, "-"+level, path, "-o", optimized)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		os.Remove(optimized)
+		return fmt.Errorf("optimizing %s: %s\nOutput: %s", path, err, output.String())
+	}
+
+	return os.Rename(optimized, path)
+}
+
+// hasWasmPlatform reports whether any of the platforms builds wasm.
+func hasWasmPlatform(platforms []Platform) bool {
+	for _, p := range platforms {
+		if p.Arch == "wasm" {
+			return true
+		}
+	}
+
+	return false
+}
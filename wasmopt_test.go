@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWasmOptStep(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as wasm-opt")
+	}
+
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// A fake wasm-opt that writes its level to the file after -o
+	wasmOpt := filepath.Join(td, "wasm-opt")
+	script := "#!/bin/sh\necho \"$1\" > \"$4\"\n"
+	if err := ioutil.WriteFile(wasmOpt, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	bin := filepath.Join(td, "app.wasm")
+	if err := ioutil.WriteFile(bin, []byte("module"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	step := wasmOptStep(wasmOpt, "O3")
+
+	// Other platforms are ignored
+	opts := &CompileOpts{Platform: Platform{OS: "linux", Arch: "amd64"}}
+	if err := step(opts, &BuildResult{Output: bin}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data, _ := ioutil.ReadFile(bin); string(data) != "module" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	opts.Platform = Platform{OS: "wasip1", Arch: "wasm"}
+	if err := step(opts, &BuildResult{Output: bin}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data, _ := ioutil.ReadFile(bin); string(data) != "-O3\n" {
+		t.Fatalf("bad: %q", data)
+	}
+	if _, err := os.Stat(bin + ".opt"); !os.IsNotExist(err) {
+		t.Fatalf("the temporary file should be gone: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long -watch waits for changes to settle before
+// rebuilding, so that saving several files rebuilds once.
+const watchDebounce = 300 * time.Millisecond
+
+// watchMaxBuilds is the number of builds above which -watch warns that
+// each change is going to take a while to build.
+const watchMaxBuilds = 8
+
+// watchRoot returns the directory -watch watches: the root of the module
+// in dir, or dir itself outside of a module.
+func watchRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := findUp(dir, "go.mod")
+	if err != nil || path == "" {
+		return dir, err
+	}
+
+	return filepath.Dir(path), nil
+}
+
+// watchBuilds builds with build whenever a source file below root
+// changes, printing the outcome of each build to out, until gox is
+// interrupted. It builds once before waiting for changes.
+func watchBuilds(root string, build func() []BuildResult, out io.Writer) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := watchDirs(w, root); err != nil {
+		return err
+	}
+
+	// done stops the goroutine below once watchLoop no longer receives
+	changes := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					close(changes)
+					return
+				}
+
+				// New directories must be watched too
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						watchDirs(w, event.Name)
+						continue
+					}
+				}
+				if isWatchedFile(event.Name) {
+					select {
+					case changes <- event.Name:
+					case <-done:
+						return
+					}
+				}
+			case err, ok := <-w.Errors:
+				if ok {
+					fmt.Fprintf(os.Stderr, "Error watching %s: %s\n", root, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	rebuild := func() {
+		printWatchReport(out, build(), time.Now())
+		fmt.Fprintf(out, "Watching %s for changes, press Ctrl-C to stop\n", root)
+	}
+	rebuild()
+	watchLoop(changes, stop, watchDebounce, func(changed string) {
+		fmt.Fprintf(out, "\n%s changed, rebuilding\n", changed)
+		rebuild()
+	})
+
+	return nil
+}
+
+// watchLoop calls rebuild once changes have stopped arriving for the
+// debounce duration, with the last file that changed, until stop
+// receives or changes is closed.
+func watchLoop(changes <-chan string, stop <-chan os.Signal, debounce time.Duration, rebuild func(changed string)) {
+	var timer <-chan time.Time
+	var changed string
+	for {
+		select {
+		case name, ok := <-changes:
+			if !ok {
+				return
+			}
+			changed = name
+			timer = time.After(debounce)
+		case <-timer:
+			timer = nil
+			rebuild(changed)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// watchDirs adds dir and the directories below it to w, skipping hidden
+// directories, vendor and testdata.
+func watchDirs(w *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+
+		name := info.Name()
+		if path != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+			name == "vendor" || name == "testdata") {
+			return filepath.SkipDir
+		}
+
+		return w.Add(path)
+	})
+}
+
+// isWatchedFile reports whether a change to the file at path can change
+// a build: Go source files and the go.mod, go.sum and go.work files. The
+// outputs of the builds are not, which keeps them from triggering builds
+// when they are written below the watched directory.
+func isWatchedFile(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	switch name {
+	case "go.mod", "go.sum", "go.work", "go.work.sum":
+		return true
+	}
+
+	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
+}
+
+// printWatchReport prints a line for each build of a watch cycle, and a
+// line with the number of builds that passed and failed.
+func printWatchReport(out io.Writer, results []BuildResult, now time.Time) {
+	sorted := append([]BuildResult{}, results...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Platform.String() != sorted[j].Platform.String() {
+			return sorted[i].Platform.String() < sorted[j].Platform.String()
+		}
+		return sorted[i].Package < sorted[j].Package
+	})
+
+	fmt.Fprintln(out)
+	failed := 0
+	for _, r := range sorted {
+		if r.Err == nil {
+			fmt.Fprintf(out, "PASS %15s: %s\n", r.Platform.String(), r.Package)
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(out, "FAIL %15s: %s: %s\n", r.Platform.String(), r.Package, firstErrorLine(r.Err))
+	}
+
+	fmt.Fprintf(out, "%d passed, %d failed at %s\n", len(sorted)-failed, failed, now.Format("15:04:05"))
+}
+
+// firstErrorLine returns the first line of the output of go build in err,
+// which is usually the first compile error, or else the first line of
+// err.
+func firstErrorLine(err error) string {
+	lines := strings.Split(err.Error(), "\n")
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "Stderr:"))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+
+	return lines[0]
+}
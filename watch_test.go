@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsWatchedFile(t *testing.T) {
+	cases := map[string]bool{
+		"main.go":               true,
+		"cmd/app/main.go":       true,
+		"go.mod":                true,
+		"go.sum":                true,
+		"go.work":               true,
+		"main_test.go":          false,
+		".#main.go":             false,
+		"app_linux_amd64":       false,
+		"app_windows_amd64.exe": false,
+		"README.md":             false,
+	}
+
+	for path, expected := range cases {
+		if actual := isWatchedFile(path); actual != expected {
+			t.Fatalf("%s: expected %v", path, expected)
+		}
+	}
+}
+
+func TestWatchLoop(t *testing.T) {
+	changes := make(chan string)
+	stop := make(chan os.Signal)
+	rebuilt := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		watchLoop(changes, stop, 50*time.Millisecond, func(changed string) {
+			rebuilt <- changed
+		})
+		close(done)
+	}()
+
+	// A burst of changes rebuilds once, with the last change
+	changes <- "a.go"
+	changes <- "b.go"
+	changes <- "c.go"
+	select {
+	case changed := <-rebuilt:
+		if changed != "c.go" {
+			t.Fatalf("bad: %s", changed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rebuild")
+	}
+
+	select {
+	case changed := <-rebuilt:
+		t.Fatalf("rebuilt again for %s", changed)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't stop")
+	}
+}
+
+func TestPrintWatchReport(t *testing.T) {
+	results := []BuildResult{
+		{Platform: Platform{OS: "windows", Arch: "amd64"}, Package: "app", Err: errors.New("exit status 1\nStderr: # app\n./main.go:3:2: undefined: foo\n")},
+		{Platform: Platform{OS: "linux", Arch: "amd64"}, Package: "app"},
+	}
+
+	results = append(results, BuildResult{
+		Platform: Platform{OS: "linux", Arch: "arm"}, Package: "app", Err: errors.New("no space left"),
+	})
+
+	var buf bytes.Buffer
+	printWatchReport(&buf, results, time.Date(2020, 1, 1, 12, 30, 5, 0, time.UTC))
+
+	expected := `
+PASS     linux/amd64: app
+FAIL       linux/arm: app: no space left
+FAIL   windows/amd64: app: ./main.go:3:2: undefined: foo
+1 passed, 2 failed at 12:30:05
+`
+	if buf.String() != expected {
+		t.Fatalf("bad:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// WinSignOpts configures Authenticode signing of windows binaries with
+// osslsigncode, or a signer that accepts the same arguments.
+type WinSignOpts struct {
+	// Cmd is the signer to run, osslsigncode by default.
+	Cmd string
+
+	// PKCS12 is the path to the certificate and key to sign with, and
+	// PassFile an optional file containing its password.
+	PKCS12   string
+	PassFile string
+
+	// TimestampURL, if set, is an RFC 3161 time-stamping server.
+	TimestampURL string
+}
+
+// winSignStep returns a post-build step that signs windows binaries in
+// place. Other platforms are left alone.
+func winSignStep(sign WinSignOpts) postBuildStep {
+	return func(opts *CompileOpts, result *BuildResult) error {
+		if opts.Platform.OS != "windows" {
+			return nil
+		}
+
+		return winSign(sign, result.Output)
+	}
+}
+
+// winSign signs the binary at path, replacing it with the signed one.
+func winSign(sign WinSignOpts, path string) error {
+	cmdName := sign.Cmd
+	if cmdName == "" {
+		cmdName = "osslsigncode"
+	}
+	if _, err := exec.LookPath(cmdName); err != nil {
+		return fmt.Errorf("%s must be on the PATH to sign windows binaries", cmdName)
+	}
+
+	signed := path + ".signed"
+	args := []string{"sign", "-pkcs12", sign.PKCS12}
+	if sign.PassFile != "" {
+		args = append(args, "-readpass", sign.PassFile)
+	}
+	if sign.TimestampURL != "" {
+		args = append(args, "-ts", sign.TimestampURL)
+	}
+	args = append(args, "-in", path, "-out", signed)
+
+	var output bytes.Buffer
+	cmd := exec.Command(cmdName, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		os.Remove(signed)
+		return fmt.Errorf("signing %s: %s\nOutput: %s", path, err, output.String())
+	}
+
+	return os.Rename(signed, path)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWinSignStep(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the signer")
+	}
+
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// A fake signer that writes "signed" to the file after -out
+	signer := filepath.Join(td, "signer")
+	script := "#!/bin/sh\nwhile [ \"$1\" != \"-out\" ]; do shift; done\necho signed > \"$2\"\n"
+	if err := ioutil.WriteFile(signer, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	bin := filepath.Join(td, "app.exe")
+	if err := ioutil.WriteFile(bin, []byte("unsigned"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	step := winSignStep(WinSignOpts{Cmd: signer, PKCS12: "cert.p12"})
+
+	// Other platforms are ignored
+	opts := &CompileOpts{Platform: Platform{OS: "linux", Arch: "amd64"}}
+	if err := step(opts, &BuildResult{Output: bin}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data, _ := ioutil.ReadFile(bin); string(data) != "unsigned" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	opts.Platform.OS = "windows"
+	if err := step(opts, &BuildResult{Output: bin}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data, _ := ioutil.ReadFile(bin); string(data) != "signed\n" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	// A missing signer fails the build
+	step = winSignStep(WinSignOpts{Cmd: filepath.Join(td, "missing")})
+	if err := step(opts, &BuildResult{Output: bin}); err == nil {
+		t.Fatal("should err")
+	}
+}
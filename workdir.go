@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// newWorkDir creates a fresh work directory for this run below parent,
+// with the GOTMPDIR and GOCACHE directories of the builds inside it.
+func newWorkDir(parent string) (string, error) {
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+
+	dir, err := ioutil.TempDir(parent, "gox-")
+	if err != nil {
+		return "", err
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	for _, sub := range []string{"tmp", "cache"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// workOutputPath returns where go build writes the output of a build in
+// the work directory. Each output gets its own directory, named after
+// the final output path, so that files go build writes next to the
+// output, such as the header of a c-shared library, aren't mixed up.
+func (opts *CompileOpts) workOutputPath(final string) string {
+	sum := sha256.Sum256([]byte(final))
+	return filepath.Join(opts.WorkDir, "out",
+		fmt.Sprintf("%x", sum[:8]), filepath.Base(final))
+}
+
+// copyWorkOutputs copies the files of the work output directory of a
+// build to the directory of its final output.
+func copyWorkOutputs(workOutput, final string) error {
+	files, err := ioutil.ReadDir(filepath.Dir(workOutput))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(final), 0755); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		src := filepath.Join(filepath.Dir(workOutput), f.Name())
+		if err := copyFile(src, filepath.Join(filepath.Dir(final), f.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewWorkDir(t *testing.T) {
+	parent, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(parent)
+
+	dir, err := newWorkDir(filepath.Join(parent, "work"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasPrefix(dir, filepath.Join(parent, "work")) {
+		t.Fatalf("bad: %s", dir)
+	}
+	for _, sub := range []string{"tmp", "cache"} {
+		if _, err := os.Stat(filepath.Join(dir, sub)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestCompileOptsBuildArgs_workDir(t *testing.T) {
+	opts := &CompileOpts{
+		PackagePath: "foo",
+		Platform:    Platform{OS: "linux", Arch: "amd64"},
+		OutputTpl:   "/out/{{.Dir}}_{{.OS}}",
+		WorkDir:     "/work",
+	}
+
+	args, err := opts.BuildArgs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var output string
+	for i, arg := range args {
+		if arg == "-o" {
+			output = args[i+1]
+		}
+	}
+	if !strings.HasPrefix(output, filepath.Join("/work", "out")+string(filepath.Separator)) ||
+		filepath.Base(output) != "foo_linux" {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestCopyWorkOutputs(t *testing.T) {
+	td, err := ioutil.TempDir("", "gox")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	work := filepath.Join(td, "work", "lib.so")
+	final := filepath.Join(td, "dist", "lib.so")
+	if err := os.MkdirAll(filepath.Dir(work), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, name := range []string{"lib.so", "lib.h"} {
+		path := filepath.Join(filepath.Dir(work), name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if err := copyWorkOutputs(work, final); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, name := range []string{"lib.so", "lib.h"} {
+		data, err := ioutil.ReadFile(filepath.Join(td, "dist", name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(data) != name {
+			t.Fatalf("bad: %s", data)
+		}
+	}
+}